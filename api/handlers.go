@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"govd/bot/core"
+	"govd/enums"
+	extractors "govd/ext"
+	"govd/models"
+	"govd/util"
+
+	"github.com/bytedance/sonic"
+	"github.com/pkg/errors"
+)
+
+const requestTimeout = 10 * time.Minute
+
+func ExtractHandler(w http.ResponseWriter, r *http.Request) {
+	contentURL := r.URL.Query().Get("url")
+	if contentURL == "" {
+		writeError(w, http.StatusBadRequest, "missing url parameter")
+		return
+	}
+	_, response, err := extractors.ExtractURL(r.Context(), contentURL)
+	if err != nil {
+		writeExtractError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func ExtractorsHandler(w http.ResponseWriter, _ *http.Request) {
+	extractorList := make([]*models.APIExtractor, 0, len(extractors.List))
+	for _, extractor := range extractors.List {
+		extractorList = append(extractorList, &models.APIExtractor{
+			Name:       extractor.Name,
+			CodeName:   extractor.CodeName,
+			Type:       extractor.Type,
+			Category:   extractor.Category,
+			Host:       extractor.Host,
+			IsDRM:      extractor.IsDRM,
+			IsRedirect: extractor.IsRedirect,
+		})
+	}
+	writeJSON(w, http.StatusOK, extractorList)
+}
+
+func DownloadHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	contentURL := query.Get("url")
+	if contentURL == "" {
+		writeError(w, http.StatusBadRequest, "missing url parameter")
+		return
+	}
+	var index int
+	if value := query.Get("index"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			writeError(w, http.StatusBadRequest, "invalid index parameter")
+			return
+		}
+		index = parsed
+	}
+
+	dlCtx, response, err := extractors.ExtractURL(r.Context(), contentURL)
+	if err != nil {
+		writeExtractError(w, err)
+		return
+	}
+	if dlCtx.Extractor.Type != enums.ExtractorTypeSingle {
+		writeError(w, http.StatusBadRequest, util.ErrUnsupportedExtractorType.Message)
+		return
+	}
+	if index >= len(response.MediaList) {
+		writeError(w, http.StatusNotFound, "media not found")
+		return
+	}
+	media := response.MediaList[index]
+
+	formatID := query.Get("format_id")
+	format := core.GetMediaFormat(media, formatID)
+	if format == nil {
+		writeError(w, http.StatusNotFound, "format not found")
+		return
+	}
+	if len(format.URL) == 0 {
+		writeError(w, http.StatusNotFound, "format has no url")
+		return
+	}
+	core.SetMediaFormat(media, format)
+
+	// downloads share the queue with the bot, and its limits
+	var medias []*models.DownloadedMedia
+	err = core.RunJob(
+		r.Context(), dlCtx,
+		requestTimeout,
+		func(taskCtx context.Context) error {
+			downloaded, err := core.DownloadMedias(taskCtx, []*models.Media{media}, nil)
+			if err != nil {
+				return fmt.Errorf("failed to download media: %w", err)
+			}
+			medias = downloaded
+			return core.RunPlugins(taskCtx, medias)
+		},
+	)
+	if len(medias) > 0 {
+		downloaded := medias[0]
+		defer func() {
+			os.Remove(downloaded.FilePath)
+			if downloaded.ThumbnailFilePath != "" {
+				os.Remove(downloaded.ThumbnailFilePath)
+			}
+		}()
+	}
+	if errors.Is(err, util.ErrQueueFull) || errors.Is(err, util.ErrShuttingDown) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	downloaded := medias[0]
+
+	file, err := os.Open(downloaded.FilePath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to open file")
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to stat file")
+		return
+	}
+
+	fileName := filepath.Base(downloaded.FilePath)
+	w.Header().Set(
+		"Content-Disposition",
+		fmt.Sprintf("attachment; filename=%q", fileName),
+	)
+	http.ServeContent(w, r, fileName, info.ModTime(), file)
+}
+
+func writeExtractError(w http.ResponseWriter, err error) {
+	if errors.Is(err, extractors.ErrUnsupportedURL) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var botError *util.Error
+	if errors.As(err, &botError) {
+		writeJSON(w, http.StatusUnprocessableEntity, &models.APIError{
+			Error: botError.Message,
+			Code:  botError.Code,
+		})
+		return
+	}
+	writeError(w, http.StatusBadGateway, err.Error())
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, &models.APIError{Error: message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	sonic.ConfigFastest.NewEncoder(w).Encode(data)
+}
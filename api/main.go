@@ -0,0 +1,83 @@
+package api
+
+import (
+	"crypto/subtle"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"govd/util"
+)
+
+// the api is only reachable locally, unless
+// API_BIND_ADDR is set to a public address
+const defaultBindAddr = "127.0.0.1"
+
+func Start(port int) {
+	token := os.Getenv("API_TOKEN")
+	if token == "" {
+		log.Println("API_TOKEN is required to start the api server")
+		return
+	}
+	bindAddr := os.Getenv("API_BIND_ADDR")
+	if bindAddr == "" {
+		bindAddr = defaultBindAddr
+	}
+
+	mux := http.NewServeMux()
+	registerHandlers(mux)
+
+	server := &http.Server{
+		Addr:              net.JoinHostPort(bindAddr, strconv.Itoa(port)),
+		Handler:           withRequestLogger(withAuth(token, mux)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("starting api server on %s", server.Addr)
+	err := server.ListenAndServe()
+	if err != nil {
+		log.Printf("api server stopped: %v", err)
+	}
+}
+
+func registerHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /extract", ExtractHandler)
+	mux.HandleFunc("GET /extractors", ExtractorsHandler)
+	mux.HandleFunc("GET /download", DownloadHandler)
+}
+
+// withAuth rejects requests without the
+// token in the Authorization header
+func withAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withRequestLogger gives every request a logger with a
+// request ID, which is also returned in the X-Request-ID header
+func withRequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := util.RandomBase64(8)
+		logger := slog.Default().With(
+			"request_id", requestID,
+			"method", r.Method,
+			"path", r.URL.Path,
+		)
+		w.Header().Set("X-Request-ID", requestID)
+
+		start := time.Now()
+		next.ServeHTTP(w, r.WithContext(util.WithLogger(r.Context(), logger)))
+		logger.Debug("request served", "duration", time.Since(start))
+	})
+}
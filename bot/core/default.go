@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"govd/database"
+	"govd/enums"
 	"govd/models"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
@@ -31,6 +32,7 @@ func HandleDefaultFormatDownload(
 		)
 	}
 
+	GetStatusMessage(taskCtx).SetPhase(enums.DownloadPhaseExtracting)
 	response, err := dlCtx.Extractor.Run(dlCtx)
 	if err != nil {
 		return fmt.Errorf("extractor fetch run failed: %w", err)
@@ -49,11 +51,24 @@ func HandleDefaultFormatDownload(
 		if len(defaultFormat.URL) == 0 {
 			return fmt.Errorf("media format at index %d has no URL", i)
 		}
-		// ensure we can merge video and audio formats
-		ensureMergeFormats(mediaList[i], defaultFormat)
-		mediaList[i].Format = defaultFormat
+		SetMediaFormat(mediaList[i], defaultFormat)
 	}
 
+	return HandleMediaListDownload(
+		bot, ctx, taskCtx,
+		dlCtx, mediaList,
+	)
+}
+
+// HandleMediaListDownload downloads medias whose
+// format has already been chosen and sends them
+func HandleMediaListDownload(
+	bot *gotgbot.Bot,
+	ctx *ext.Context,
+	taskCtx context.Context,
+	dlCtx *models.DownloadContext,
+	mediaList []*models.Media,
+) error {
 	medias, err := DownloadMedias(taskCtx, mediaList, nil)
 	if err != nil {
 		return fmt.Errorf("failed to download media list: %w", err)
@@ -72,25 +87,36 @@ func HandleDefaultFormatDownload(
 		isCaptionEnabled,
 	)
 
-	// plugins act as post-processing for the media.
-	// they are run after the media is downloaded
-	// and before it is sent to the user
-	// this allows for things like merging audio and video, etc.
-	for _, media := range medias {
-		for _, plugin := range media.Media.Format.Plugins {
-			err = plugin(media)
-			if err != nil {
-				return fmt.Errorf("failed to run plugin: %w", err)
-			}
-		}
+	status := GetStatusMessage(taskCtx)
+	if hasPlugins(medias) {
+		status.SetPhase(enums.DownloadPhaseMerging)
+	}
+	err = RunPlugins(taskCtx, medias)
+	if err != nil {
+		return err
+	}
+	err = TranscodeMedias(taskCtx, dlCtx, medias)
+	if err != nil {
+		return err
 	}
 
+	fittedMedias, notes, err := FitUploadLimit(taskCtx, medias)
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		messageCaption += "<i>" + note + "</i>\n"
+	}
+
+	status.SetPhase(enums.DownloadPhaseUploading)
 	_, err = SendMedias(
 		bot, ctx, dlCtx,
-		medias,
+		fittedMedias,
 		&models.SendMediaFormatsOptions{
 			Caption:  messageCaption,
 			IsStored: false,
+			// split parts can't be stored as a single media
+			SkipCache: len(fittedMedias) != len(medias),
 		},
 	)
 	if err != nil {
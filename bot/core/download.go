@@ -29,6 +29,28 @@ func downloadMediaItem(
 	if format == nil {
 		return nil, errors.New("media format is nil")
 	}
+	if status := GetStatusMessage(ctx); status != nil {
+		// each item reports its own progress
+		itemConfig := *config
+		itemConfig.ProgressUpdater = status.ProgressUpdater(idx)
+		config = &itemConfig
+	}
+	config = util.FormatDownloadConfig(
+		config,
+		media.ExtractorCodeName,
+		format,
+	)
+	if key := resumeKey(ctx, idx); key != "" {
+		resumeConfig := *config
+		resumeConfig.ResumeKey = key
+		config = &resumeConfig
+	}
+	if format.Type == enums.MediaTypeAudio && config.Remux {
+		// remuxing only supports video containers
+		audioConfig := *config
+		audioConfig.Remux = false
+		config = &audioConfig
+	}
 
 	fileName := format.GetFileName()
 	var filePath string
@@ -81,7 +103,7 @@ func downloadMediaItem(
 	}
 
 	if format.Type == enums.MediaTypeVideo || format.Type == enums.MediaTypeAudio {
-		path, err := getFileThumbnail(ctx, format, filePath)
+		path, err := getFileThumbnail(ctx, format, filePath, config)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get thumbnail: %w", err)
 		}
@@ -147,6 +169,10 @@ func DownloadMedias(
 		return []*models.DownloadedMedia{}, nil
 	}
 
+	status := GetStatusMessage(ctx)
+	status.SetItems(len(medias))
+	status.SetPhase(enums.DownloadPhaseDownloading)
+
 	if len(medias) == 1 {
 		result, err := DownloadMedia(ctx, medias[0], config)
 		if err != nil {
@@ -0,0 +1,269 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"govd/database"
+	"govd/enums"
+	"govd/models"
+	"govd/plugins"
+	"govd/util"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+type FormatsTask struct {
+	DownloadContext *models.DownloadContext
+	Media           *models.Media
+	Formats         []*models.MediaFormat
+	UserID          int64
+}
+
+type FormatsTaskEntry struct {
+	Task      *FormatsTask
+	CreatedAt time.Time
+}
+
+var FormatsTasks sync.Map
+var formatsCleanupActive sync.Once
+
+const (
+	formatsTaskTimeout = 10 * time.Minute
+	maxFormatButtons   = 20
+)
+
+func GetFormatsTask(id string) (*FormatsTask, bool) {
+	value, ok := FormatsTasks.Load(id)
+	if !ok {
+		return nil, false
+	}
+	entry, ok := value.(FormatsTaskEntry)
+	if !ok {
+		return nil, false
+	}
+	return entry.Task, true
+}
+
+func SetFormatsTask(id string, task *FormatsTask) {
+	FormatsTasks.Store(id, FormatsTaskEntry{
+		Task:      task,
+		CreatedAt: time.Now(),
+	})
+	formatsCleanupActive.Do(startFormatsTasksCleanup)
+}
+
+func DeleteFormatsTask(id string) {
+	FormatsTasks.Delete(id)
+}
+
+func startFormatsTasksCleanup() {
+	go func() {
+		ticker := time.NewTicker(1 * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			now := time.Now()
+			FormatsTasks.Range(func(key, value any) bool {
+				entry, ok := value.(FormatsTaskEntry)
+				if !ok || now.Sub(entry.CreatedAt) > formatsTaskTimeout {
+					FormatsTasks.Delete(key)
+				}
+				return true
+			})
+		}
+	}()
+}
+
+func HandleFormatsList(
+	bot *gotgbot.Bot,
+	ctx *ext.Context,
+	dlCtx *models.DownloadContext,
+) error {
+	if dlCtx.Extractor.Type != enums.ExtractorTypeSingle {
+		return util.ErrUnsupportedExtractorType
+	}
+	response, err := dlCtx.Extractor.Run(dlCtx)
+	if err != nil {
+		return fmt.Errorf("extractor fetch run failed: %w", err)
+	}
+	mediaList := response.MediaList
+	if len(mediaList) == 0 {
+		return util.ErrUnavailable
+	}
+	if len(mediaList) > 1 {
+		return util.ErrFormatsMediaGroup
+	}
+	media := mediaList[0]
+
+	formats := media.GetSortedFormats()
+	if len(formats) > maxFormatButtons {
+		formats = formats[:maxFormatButtons]
+	}
+	audioFormat := GetAudioFromVideoFormat(media)
+	if audioFormat != nil {
+		formats = append(formats, audioFormat)
+	}
+	if len(formats) == 0 {
+		return util.ErrUnavailable
+	}
+
+	taskID := util.RandomBase64(8)
+	keyboard := make([][]gotgbot.InlineKeyboardButton, 0, len(formats))
+	for idx, format := range formats {
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+			{
+				Text:         FormatButtonText(format),
+				CallbackData: fmt.Sprintf("format:%s:%d", taskID, idx),
+			},
+		})
+	}
+
+	_, err = ctx.EffectiveMessage.Reply(
+		bot,
+		"choose a format to download",
+		&gotgbot.SendMessageOpts{
+			ReplyMarkup: gotgbot.InlineKeyboardMarkup{
+				InlineKeyboard: keyboard,
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to send formats list: %w", err)
+	}
+
+	SetFormatsTask(taskID, &FormatsTask{
+		DownloadContext: dlCtx,
+		Media:           media,
+		Formats:         formats,
+		UserID:          ctx.EffectiveUser.Id,
+	})
+	return nil
+}
+
+func HandleFormatDownload(
+	bot *gotgbot.Bot,
+	ctx *ext.Context,
+	taskCtx context.Context,
+	task *FormatsTask,
+	format *models.MediaFormat,
+) error {
+	dlCtx := task.DownloadContext
+	media := task.Media
+
+	storedMedias, err := database.GetFormatMedias(
+		dlCtx.Extractor.CodeName,
+		dlCtx.MatchedContentID,
+		format.FormatID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get format medias: %w", err)
+	}
+	if len(storedMedias) > 0 {
+		return HandleDefaultStoredFormatDownload(
+			bot, ctx, dlCtx, storedMedias,
+		)
+	}
+
+	if len(format.URL) == 0 {
+		return fmt.Errorf("media format %s has no URL", format.FormatID)
+	}
+	SetMediaFormat(media, format)
+
+	return HandleMediaListDownload(
+		bot, ctx, taskCtx,
+		dlCtx, []*models.Media{media},
+	)
+}
+
+// GetAudioFromVideoFormat returns a format that
+// extracts the audio track from the default video,
+// or nil if the media doesn't support it
+func GetAudioFromVideoFormat(media *models.Media) *models.MediaFormat {
+	if !media.SupportsAudioFromVideo() {
+		return nil
+	}
+	audioFormat := media.GetAudioFromVideoFormat()
+	if audioFormat == nil {
+		return nil
+	}
+	// audio can only be extracted from single-file videos
+	if len(media.GetDefaultVideoFormat().Segments) > 0 {
+		return nil
+	}
+	audioFormat.Plugins = append(audioFormat.Plugins, plugins.ExtractAudio)
+	return audioFormat
+}
+
+// GetMediaFormat returns the format with the given ID,
+// or the default format if formatID is empty
+func GetMediaFormat(
+	media *models.Media,
+	formatID string,
+) *models.MediaFormat {
+	switch formatID {
+	case "":
+		return media.GetDefaultFormat()
+	case "AudioFromVideo":
+		return GetAudioFromVideoFormat(media)
+	default:
+		return media.GetFormat(formatID)
+	}
+}
+
+func FormatButtonText(format *models.MediaFormat) string {
+	var parts []string
+
+	switch format.Type {
+	case enums.MediaTypeVideo:
+		if format.Height > 0 {
+			parts = append(parts, fmt.Sprintf("%dp", format.Height))
+		}
+		if format.VideoCodec != "" {
+			parts = append(parts, string(format.VideoCodec))
+		}
+	case enums.MediaTypeAudio:
+		parts = append(parts, "audio")
+		if format.AudioCodec != "" {
+			parts = append(parts, string(format.AudioCodec))
+		}
+		if format.FormatID == "AudioFromVideo" {
+			parts = append(parts, "(from video)")
+		}
+	case enums.MediaTypePhoto:
+		parts = append(parts, "photo")
+		if format.Width > 0 && format.Height > 0 {
+			parts = append(parts, fmt.Sprintf("%dx%d", format.Width, format.Height))
+		}
+	default:
+		parts = append(parts, format.FormatID)
+	}
+
+	if format.Bitrate > 0 {
+		parts = append(parts, formatBitrate(format.Bitrate))
+		if format.Duration > 0 {
+			size := format.Bitrate * format.Duration / 8
+			parts = append(parts, "~"+formatFileSize(size))
+		}
+	}
+
+	return strings.Join(parts, " | ")
+}
+
+func formatBitrate(bitrate int64) string {
+	if bitrate >= 1000*1000 {
+		return fmt.Sprintf("%.1f mbps", float64(bitrate)/(1000*1000))
+	}
+	return fmt.Sprintf("%d kbps", bitrate/1000)
+}
+
+func formatFileSize(size int64) string {
+	if size >= 1024*1024 {
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	}
+	return fmt.Sprintf("%d KB", size/1024)
+}
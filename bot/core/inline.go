@@ -114,6 +114,9 @@ func HandleInline(
 	return nil
 }
 
+// HandleInlineCached answers the query with the cached media.
+// telegram sends it without the bot, so it doesn't count
+// towards the daily limits
 func HandleInlineCached(
 	bot *gotgbot.Bot,
 	ctx *ext.Context,
@@ -193,6 +196,12 @@ func HandleInlineCachedResult(
 	if err != nil {
 		return err
 	}
+	RecordUsage(
+		ctx.EffectiveUser.Id,
+		0,
+		nil,
+		format.FileSize,
+	)
 	return nil
 }
 
@@ -283,6 +292,37 @@ func GetInlineFormat(
 		errChan <- fmt.Errorf("failed to download medias: %w", err)
 		return
 	}
+	if hasPlugins(medias) {
+		GetStatusMessage(taskCtx).SetPhase(enums.DownloadPhaseMerging)
+	}
+	err = RunPlugins(taskCtx, medias)
+	if err != nil {
+		errChan <- err
+		return
+	}
+	err = TranscodeMedias(taskCtx, dlCtx, medias)
+	if err != nil {
+		errChan <- err
+		return
+	}
+	fittedMedias, notes, err := FitUploadLimit(taskCtx, medias)
+	if err != nil {
+		errChan <- err
+		return
+	}
+	if len(fittedMedias) != len(medias) {
+		// split parts can't be sent inline
+		for _, media := range fittedMedias {
+			removeDownloadedMedia(media)
+		}
+		errChan <- util.ErrInlineMediaGroup
+		return
+	}
+	medias = fittedMedias
+	for _, note := range notes {
+		messageCaption += "<i>" + note + "</i>\n"
+	}
+	GetStatusMessage(taskCtx).SetPhase(enums.DownloadPhaseUploading)
 	msgs, err := SendMedias(
 		bot, ctx, dlCtx,
 		medias, &models.SendMediaFormatsOptions{
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"govd/util"
+
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+const (
+	downloadErrorKey = "download_error"
+	canceledKey      = "canceled"
+	retryAfterKey    = "retry_after"
+)
+
+// translations of user messages, by telegram language
+// code and error code. english is the default
+var translations = map[string]map[string]string{
+	"it": {
+		downloadErrorKey:                     "errore durante il download: ",
+		canceledKey:                          "download annullato o scaduto",
+		retryAfterKey:                        ". riprova tra %s",
+		util.ErrUnavailable.Code:             "questo contenuto non è disponibile",
+		util.ErrTimeout.Code:                 "il download ha impiegato troppo tempo. riprova",
+		util.ErrMediaGroupLimitExceeded.Code: "limite di media superato per questo gruppo. prova a modificare /settings",
+		util.ErrNSFWNotAllowed.Code:          "questo contenuto è nsfw e non può essere scaricato in questo gruppo. prova a modificare /settings o usami in privato",
+		util.ErrInlineMediaGroup.Code:        "non puoi scaricare gruppi di media in modalità inline. prova a usarmi in privato",
+		util.ErrFormatsMediaGroup.Code:       "la scelta del formato non è disponibile per i gruppi di media",
+		util.ErrFormatsTaskExpired.Code:      "questa lista di formati è scaduta. invia di nuovo il link",
+		util.ErrEmptyPlaylist.Code:           "nessun elemento scaricabile in questa playlist",
+		util.ErrPlaylistFailed.Code:          "impossibile scaricare gli elementi di questa playlist",
+		util.ErrQueueFull.Code:               "il bot è occupato in questo momento. riprova più tardi",
+		util.ErrFileTooLarge.Code:            "questo file è troppo grande per essere caricato",
+		util.ErrShuttingDown.Code:            "il bot si sta riavviando. riprova tra un momento",
+		util.ErrJobInterrupted.Code:          "il bot si sta riavviando. il download riprenderà a breve",
+		util.ErrContentPrivate.Code:          "questo contenuto è privato",
+		util.ErrContentDeleted.Code:          "questo contenuto è stato eliminato",
+		util.ErrGeoBlocked.Code:              "questo contenuto non è disponibile nel paese del bot",
+		util.ErrLoginRequired.Code:           "questo contenuto richiede un account, e il bot non può accedervi al momento",
+		util.ErrPlatformRateLimited.Code:     "la piattaforma sta limitando le richieste del bot. riprova più tardi",
+		util.ErrAgeRestricted.Code:           "questo contenuto ha limiti di età",
+		util.ErrUnsupportedPostType.Code:     "questo tipo di post non è supportato",
+		util.ErrUpstreamChanged.Code:         "la piattaforma è cambiata e il bot non riesce ancora a leggere questo contenuto. riprova più tardi",
+		util.ErrChatRateLimited.Code:         "troppe richieste in questo gruppo",
+		util.ErrUserRateLimited.Code:         "stai inviando troppe richieste",
+		util.ErrChatQuotaReached.Code:        "limite giornaliero di download raggiunto per questo gruppo",
+		util.ErrUserQuotaReached.Code:        "hai raggiunto la tua quota giornaliera di download",
+	},
+}
+
+func getLanguageCode(ctx *ext.Context) string {
+	if ctx.EffectiveUser == nil {
+		return ""
+	}
+	// e.g. "pt-br" uses "pt" translations
+	languageCode, _, _ := strings.Cut(ctx.EffectiveUser.LanguageCode, "-")
+	return languageCode
+}
+
+func translate(languageCode string, key string, fallback string) string {
+	if message, ok := translations[languageCode][key]; ok {
+		return message
+	}
+	return fallback
+}
+
+// localizeError returns the message of
+// the error in the user's language
+func localizeError(ctx *ext.Context, botError *util.Error) string {
+	return translate(getLanguageCode(ctx), botError.Code, botError.Message)
+}
+
+// localizeRateLimitError returns the reason of the
+// limit and when to retry, in the user's language
+func localizeRateLimitError(ctx *ext.Context, err *util.RateLimitError) string {
+	retryAfter := translate(getLanguageCode(ctx), retryAfterKey, ". try again in %s")
+	return localizeError(ctx, err.Kind) + fmt.Sprintf(retryAfter, util.FormatRetryAfter(err.RetryAfter))
+}
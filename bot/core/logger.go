@@ -0,0 +1,46 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+
+	"govd/util"
+
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+const loggerDataKey = "logger"
+
+// GetUpdateLogger returns the logger of the update, with a
+// request ID and the chat and user IDs. it's created once,
+// so every line logged for the update has the same ID
+func GetUpdateLogger(ctx *ext.Context) *slog.Logger {
+	if logger, ok := ctx.Data[loggerDataKey].(*slog.Logger); ok {
+		return logger
+	}
+	args := []any{"request_id", util.RandomBase64(8)}
+	if ctx.EffectiveChat != nil {
+		args = append(args, "chat_id", ctx.EffectiveChat.Id)
+	}
+	if ctx.EffectiveUser != nil {
+		args = append(args, "user_id", ctx.EffectiveUser.Id)
+	}
+	logger := slog.Default().With(args...)
+	SetUpdateLogger(ctx, logger)
+	return logger
+}
+
+// SetUpdateLogger replaces the logger of the update,
+// e.g. to add the extractor once the url is matched
+func SetUpdateLogger(ctx *ext.Context, logger *slog.Logger) {
+	if ctx.Data == nil {
+		ctx.Data = make(map[string]any)
+	}
+	ctx.Data[loggerDataKey] = logger
+}
+
+// UpdateContext returns a context
+// carrying the logger of the update
+func UpdateContext(ctx *ext.Context) context.Context {
+	return util.WithLogger(context.Background(), GetUpdateLogger(ctx))
+}
@@ -12,6 +12,7 @@ import (
 	"govd/enums"
 	"govd/models"
 	"govd/util"
+	"govd/util/metrics"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
@@ -32,6 +33,14 @@ func HandleDownloadRequest(
 		}
 		return nil
 	}
+	if dlCtx.Extractor.Type == enums.ExtractorTypePlaylist {
+		TypingEffect(bot, chatID)
+		err := HandlePlaylistDownload(bot, ctx, taskCtx, dlCtx)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
 	return util.ErrUnsupportedExtractorType
 }
 
@@ -116,14 +125,24 @@ func SendMedias(
 		}
 		mediaType := chunk[0].Media.Format.Type
 		SendingEffect(bot, chatID, mediaType)
+		uploadStart := time.Now()
 		msgs, err := bot.SendMediaGroup(
 			chatID,
 			inputMediaList,
 			messageOptions,
 		)
+		metrics.UploadDuration.ObserveSince(uploadStart)
+		logger := GetUpdateLogger(ctx).With(
+			"medias", len(inputMediaList),
+			"duration", time.Since(uploadStart),
+		)
 		if err != nil {
+			metrics.Uploads.Inc("error")
+			logger.Warn("failed to upload medias", "error", err)
 			return nil, err
 		}
+		metrics.Uploads.Inc("success")
+		logger.Debug("uploaded medias")
 
 		sentMessages = append(sentMessages, msgs...)
 		if sentMessages[0].Chat.Type != "private" {
@@ -136,14 +155,33 @@ func SendMedias(
 		return nil, errors.New("no messages sent")
 	}
 	if !options.IsStored {
-		err := StoreMedias(
-			dlCtx,
-			sentMessages,
-			medias,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to cache formats: %w", err)
+		if !options.SkipCache {
+			err := StoreMedias(
+				dlCtx,
+				sentMessages,
+				medias,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("failed to cache formats: %w", err)
+			}
 		}
 	}
+	if ctx.ChosenInlineResult != nil {
+		// inline medias are only uploaded here, their
+		// usage is recorded once the inline message is sent
+		return sentMessages, nil
+	}
+	// cached medias count towards the daily
+	// limits too, like any delivered media
+	var totalSize int64
+	for idx := range sentMessages {
+		totalSize += GetMessageFileSize(&sentMessages[idx])
+	}
+	RecordUsage(
+		ctx.EffectiveUser.Id,
+		chatID,
+		dlCtx.GroupSettings,
+		totalSize,
+	)
 	return sentMessages, nil
 }
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"govd/enums"
+	extractors "govd/ext"
+	"govd/models"
+	"govd/util"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/pkg/errors"
+)
+
+const defaultPlaylistItemsLimit = 10
+
+func HandlePlaylistDownload(
+	bot *gotgbot.Bot,
+	ctx *ext.Context,
+	taskCtx context.Context,
+	dlCtx *models.DownloadContext,
+) error {
+	response, err := dlCtx.Extractor.Run(dlCtx)
+	if err != nil {
+		return fmt.Errorf("extractor fetch run failed: %w", err)
+	}
+
+	urlList := response.URLList
+	if len(urlList) == 0 {
+		return util.ErrEmptyPlaylist
+	}
+
+	itemsLimit := GetPlaylistItemsLimit()
+	if dlCtx.GroupSettings != nil && dlCtx.GroupSettings.MediaGroupLimit < itemsLimit {
+		itemsLimit = dlCtx.GroupSettings.MediaGroupLimit
+	}
+	if len(urlList) > itemsLimit {
+		urlList = urlList[:max(itemsLimit, 0)]
+	}
+	if len(urlList) == 0 {
+		// the group limit allows no items
+		return util.ErrEmptyPlaylist
+	}
+
+	status := GetStatusMessage(taskCtx)
+	status.SetHeader(formatPlaylistStatus(0, len(urlList), 0))
+
+	var lastErr error
+	var failed int
+	for idx, itemURL := range urlList {
+		select {
+		case <-taskCtx.Done():
+			return taskCtx.Err()
+		default:
+		}
+
+		// the request is checked once, but every
+		// item counts towards the daily quota
+		err := CheckDailyQuota(
+			ctx.EffectiveUser.Id,
+			ctx.EffectiveChat.Id,
+			dlCtx.GroupSettings,
+		)
+		var quotaErr *util.RateLimitError
+		if idx > 0 && errors.As(err, &quotaErr) {
+			// the items already sent are kept
+			status.Finish(fmt.Sprintf(
+				"playlist downloaded (%d/%d)\n%s",
+				idx-failed, len(urlList),
+				localizeRateLimitError(ctx, quotaErr),
+			))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = handlePlaylistItem(bot, ctx, taskCtx, dlCtx, itemURL)
+		if err != nil {
+			util.GetLogger(taskCtx).Warn(
+				"failed to download playlist item",
+				"url", itemURL,
+				"error", err,
+			)
+			lastErr = err
+			failed++
+		}
+		status.SetHeader(formatPlaylistStatus(idx+1, len(urlList), failed))
+	}
+
+	if failed == len(urlList) {
+		if lastErr == nil {
+			return util.ErrPlaylistFailed
+		}
+		return fmt.Errorf("%w: %w", util.ErrPlaylistFailed, lastErr)
+	}
+	if failed > 0 {
+		status.Finish(formatPlaylistStatus(len(urlList), len(urlList), failed))
+	}
+	return nil
+}
+
+func handlePlaylistItem(
+	bot *gotgbot.Bot,
+	ctx *ext.Context,
+	taskCtx context.Context,
+	dlCtx *models.DownloadContext,
+	itemURL string,
+) error {
+	itemCtx, err := extractors.CtxByURL(taskCtx, itemURL)
+	if err != nil {
+		return fmt.Errorf("failed to resolve item: %w", err)
+	}
+	if itemCtx == nil || itemCtx.Extractor == nil {
+		return util.ErrUnsupportedExtractorType
+	}
+	if itemCtx.Extractor.Type != enums.ExtractorTypeSingle {
+		// nested playlists are not supported
+		return util.ErrUnsupportedExtractorType
+	}
+	itemCtx.GroupSettings = dlCtx.GroupSettings
+	return HandleDefaultFormatDownload(bot, ctx, taskCtx, itemCtx)
+}
+
+func GetPlaylistItemsLimit() int {
+	limit, err := strconv.Atoi(os.Getenv("PLAYLIST_ITEMS_LIMIT"))
+	if err != nil || limit < 1 {
+		return defaultPlaylistItemsLimit
+	}
+	return limit
+}
+
+func formatPlaylistStatus(done int, total int, failed int) string {
+	status := fmt.Sprintf("downloading playlist... (%d/%d)", done, total)
+	if done == total {
+		status = fmt.Sprintf("playlist downloaded (%d/%d)", done-failed, total)
+	}
+	if failed > 0 {
+		status += fmt.Sprintf("\nfailed items: %d", failed)
+	}
+	return status
+}
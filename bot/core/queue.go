@@ -0,0 +1,643 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"govd/config"
+	"govd/database"
+	"govd/models"
+	"govd/util"
+	"govd/util/metrics"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+type JobPriority int
+
+const (
+	JobPriorityGroup JobPriority = iota
+	JobPriorityPrivate
+	JobPriorityInline
+)
+
+type Job struct {
+	ID              string
+	UserID          int64
+	ChatID          int64
+	Bot             *gotgbot.Bot
+	Context         *ext.Context
+	DownloadContext *models.DownloadContext
+	Priority        JobPriority
+	Timeout         time.Duration
+	Run             func(taskCtx context.Context) error
+	// URL is set for jobs requested with a message.
+	// they are stored until done, so they can be
+	// queued again if the bot restarts
+	URL string
+
+	seq           uint64
+	ctx           context.Context
+	cancel        context.CancelCauseFunc
+	mu            sync.Mutex
+	started       bool
+	statusMessage *gotgbot.Message
+	// set when the job is stopped by a shutdown
+	interrupted bool
+	// receives the result of jobs run with RunJob
+	done chan error
+}
+
+type jobQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []*Job
+	running map[string]int
+	idle    int
+	seq     uint64
+	// closed stops accepting jobs on shutdown
+	closed bool
+	// tracks running jobs, to wait for them on shutdown
+	wg sync.WaitGroup
+}
+
+var queue *jobQueue
+var queueActive sync.Once
+
+type jobContextKey struct{}
+
+// Jobs holds queued and running jobs by ID
+var Jobs sync.Map
+
+const (
+	defaultQueueWorkers = 10
+	defaultQueueSize    = 500
+	// time given to interrupted jobs to
+	// clean up and notify their users
+	interruptGracePeriod = 10 * time.Second
+)
+
+// EnqueueJob adds a job to the global download queue.
+// if the job can't start right away, the user is told
+// its position in the queue
+func EnqueueJob(job *Job) error {
+	queueActive.Do(startQueue)
+
+	Jobs.Store(job.ID, job)
+	// store the job before it can start,
+	// so a finished job is never left behind
+	job.persist()
+	position, err := queue.push(job)
+	if err != nil {
+		Jobs.Delete(job.ID)
+		job.forget()
+		job.cancel(nil)
+		return err
+	}
+	if position > 0 {
+		job.sendQueueMessage(position)
+	}
+	return nil
+}
+
+// NewJob returns a job for the given update,
+// prioritizing inline and private requests
+func NewJob(
+	bot *gotgbot.Bot,
+	ctx *ext.Context,
+	dlCtx *models.DownloadContext,
+	timeout time.Duration,
+	run func(taskCtx context.Context) error,
+) *Job {
+	priority := JobPriorityGroup
+	switch {
+	case ctx.ChosenInlineResult != nil:
+		priority = JobPriorityInline
+	case ctx.EffectiveChat != nil && ctx.EffectiveChat.Type == "private":
+		priority = JobPriorityPrivate
+	}
+	var chatID int64
+	if ctx.EffectiveChat != nil {
+		chatID = ctx.EffectiveChat.Id
+	}
+	jobCtx, cancel := context.WithCancelCause(context.Background())
+	return &Job{
+		ID:              util.RandomBase64(8),
+		UserID:          ctx.EffectiveUser.Id,
+		ChatID:          chatID,
+		Bot:             bot,
+		Context:         ctx,
+		DownloadContext: dlCtx,
+		Priority:        priority,
+		Timeout:         timeout,
+		Run:             run,
+		ctx:             jobCtx,
+		cancel:          cancel,
+	}
+}
+
+// RunJob queues a download not requested with an update,
+// like the ones of the api, and waits for its result.
+// canceling ctx cancels the job, queued or running
+func RunJob(
+	ctx context.Context,
+	dlCtx *models.DownloadContext,
+	timeout time.Duration,
+	run func(taskCtx context.Context) error,
+) error {
+	jobCtx, cancel := context.WithCancelCause(context.Background())
+	job := &Job{
+		ID:              util.RandomBase64(8),
+		DownloadContext: dlCtx,
+		Priority:        JobPriorityGroup,
+		Timeout:         timeout,
+		Run:             run,
+		ctx:             jobCtx,
+		cancel:          cancel,
+		done:            make(chan error, 1),
+	}
+	err := EnqueueJob(job)
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-job.done:
+		return err
+	case <-ctx.Done():
+	}
+	job.cancel(util.ErrDownloadCanceled)
+	if queue.remove(job) {
+		Jobs.Delete(job.ID)
+		return ctx.Err()
+	}
+	// wait for the running job to stop, so
+	// its files are not left to the caller
+	return <-job.done
+}
+
+func GetJob(id string) (*Job, bool) {
+	value, ok := Jobs.Load(id)
+	if !ok {
+		return nil, false
+	}
+	job, ok := value.(*Job)
+	return job, ok
+}
+
+// GetUserJobs returns the jobs requested
+// by the user in the given chat
+func GetUserJobs(userID int64, chatID int64) []*Job {
+	var jobs []*Job
+	Jobs.Range(func(_, value any) bool {
+		job, ok := value.(*Job)
+		if ok && job.UserID == userID && job.ChatID == chatID {
+			jobs = append(jobs, job)
+		}
+		return true
+	})
+	return jobs
+}
+
+// Cancel stops the job. a queued job is
+// removed from the queue, a running job
+// has its context canceled and its partial
+// downloads removed
+func (job *Job) Cancel() {
+	job.cancel(util.ErrDownloadCanceled)
+	if !queue.remove(job) {
+		return
+	}
+	Jobs.Delete(job.ID)
+	job.forget()
+
+	job.deleteQueueMessage()
+}
+
+// DrainJobs stops accepting jobs and waits for the running
+// ones until ctx is done, then interrupts them. stored jobs
+// interrupted or still queued are kept to resume after the
+// restart, the others are aborted. users are told either way.
+// it reports whether every job stopped
+func DrainJobs(ctx context.Context) bool {
+	queueActive.Do(startQueue)
+
+	queue.mu.Lock()
+	queue.closed = true
+	pending := queue.pending
+	queue.pending = nil
+	queue.mu.Unlock()
+
+	for _, job := range pending {
+		job.interrupt()
+		Jobs.Delete(job.ID)
+		job.cancel(nil)
+		if job.done != nil {
+			job.done <- util.ErrShuttingDown
+			continue
+		}
+		job.deleteQueueMessage()
+		HandleErrorMessage(job.Bot, job.Context, job.interruptError())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		queue.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+	}
+
+	slog.Warn("shutdown timeout reached, interrupting running jobs")
+	Jobs.Range(func(_, value any) bool {
+		if job, ok := value.(*Job); ok {
+			job.interrupt()
+			// unlike Cancel, partial downloads are
+			// kept for the job to resume them
+			job.cancel(nil)
+		}
+		return true
+	})
+	select {
+	case <-done:
+		return true
+	case <-time.After(interruptGracePeriod):
+		slog.Warn("some jobs did not stop in time")
+		return false
+	}
+}
+
+func startQueue() {
+	queue = &jobQueue{
+		running: make(map[string]int),
+	}
+	queue.cond = sync.NewCond(&queue.mu)
+
+	workers := getEnvLimit("QUEUE_WORKERS", defaultQueueWorkers)
+	if workers < 1 {
+		workers = defaultQueueWorkers
+	}
+	for range workers {
+		go queue.worker()
+	}
+	slog.Info("started download queue", "workers", workers)
+
+	metrics.NewGaugeFunc(
+		"govd_queue_pending_jobs",
+		"jobs waiting in the download queue",
+		queue.pendingCount,
+	)
+	metrics.NewGaugeFunc(
+		"govd_queue_running_jobs",
+		"jobs being downloaded",
+		queue.runningCount,
+	)
+}
+
+func (q *jobQueue) pendingCount() float64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return float64(len(q.pending))
+}
+
+func (q *jobQueue) runningCount() float64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var running int
+	for _, count := range q.running {
+		running += count
+	}
+	return float64(running)
+}
+
+func (q *jobQueue) push(job *Job) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return 0, util.ErrShuttingDown
+	}
+	if len(q.pending) >= getQueueSize() {
+		return 0, util.ErrQueueFull
+	}
+	q.seq++
+	job.seq = q.seq
+
+	// keep pending jobs sorted by priority, then by arrival
+	idx, _ := slices.BinarySearchFunc(q.pending, job, compareJobs)
+	q.pending = slices.Insert(q.pending, idx, job)
+	q.cond.Signal()
+
+	position := idx + 1 - q.idle
+	if position < 0 {
+		position = 0
+	}
+	return position, nil
+}
+
+func (q *jobQueue) remove(job *Job) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	idx := slices.Index(q.pending, job)
+	if idx < 0 {
+		return false
+	}
+	q.pending = slices.Delete(q.pending, idx, idx+1)
+	return true
+}
+
+// next returns the first pending job whose
+// extractor is below its concurrency limit
+func (q *jobQueue) next() *Job {
+	for idx, job := range q.pending {
+		codeName := job.DownloadContext.Extractor.CodeName
+		limit := getExtractorConcurrency(codeName)
+		if limit > 0 && q.running[codeName] >= limit {
+			continue
+		}
+		q.pending = slices.Delete(q.pending, idx, idx+1)
+		q.running[codeName]++
+		return job
+	}
+	return nil
+}
+
+func (q *jobQueue) worker() {
+	for {
+		q.mu.Lock()
+		job := q.next()
+		for job == nil {
+			q.idle++
+			q.cond.Wait()
+			q.idle--
+			job = q.next()
+		}
+		// added with the lock held, so a
+		// shutdown never misses the job
+		q.wg.Add(1)
+		q.mu.Unlock()
+
+		job.start()
+		q.wg.Done()
+
+		q.mu.Lock()
+		q.running[job.DownloadContext.Extractor.CodeName]--
+		q.mu.Unlock()
+		// a slot was freed, jobs skipped
+		// by the extractor limit may run now
+		q.cond.Broadcast()
+	}
+}
+
+func (job *Job) start() {
+	job.mu.Lock()
+	job.started = true
+	statusMessage := job.statusMessage
+	job.mu.Unlock()
+
+	// interrupted jobs are kept stored to be
+	// restored, unless they finished anyway
+	var resumable bool
+	defer Jobs.Delete(job.ID)
+	defer func() {
+		if !resumable {
+			job.forget()
+		}
+	}()
+	defer job.cancel(nil)
+
+	if job.done != nil {
+		job.done <- job.runDetached()
+		return
+	}
+
+	// the queue message becomes the status message
+	status := NewStatusMessage(job.Bot, job.Context, statusMessage, job.ID)
+	status.Start()
+
+	taskCtx, cancel := context.WithTimeout(job.ctx, job.Timeout)
+	defer cancel()
+	taskCtx = WithStatusMessage(taskCtx, status)
+	taskCtx = context.WithValue(taskCtx, jobContextKey{}, job)
+	// sticky proxies keep the same proxy for the content
+	taskCtx = util.WithProxyKey(taskCtx, job.DownloadContext.MatchedContentID)
+	logger := GetUpdateLogger(job.Context).With("job_id", job.ID)
+	taskCtx = util.WithLogger(taskCtx, logger)
+
+	defer func() {
+		if r := recover(); r != nil {
+			status.Close()
+			logger.Error("panic occurred while running job", "panic", r)
+		}
+	}()
+	start := time.Now()
+	logger.Debug("job started")
+	err := job.Run(taskCtx)
+	status.Close()
+	if err != nil && job.isInterrupted() {
+		resumable = job.isStored()
+		logger.Info("job interrupted by shutdown", "resumable", resumable)
+		HandleErrorMessage(job.Bot, job.Context, job.interruptError())
+		return
+	}
+	if err != nil {
+		HandleErrorMessage(job.Bot, job.Context, err)
+		return
+	}
+	logger.Debug("job finished", "duration", time.Since(start))
+}
+
+// runDetached runs a job of RunJob, which
+// has no update to send messages to
+func (job *Job) runDetached() (err error) {
+	taskCtx, cancel := context.WithTimeout(job.ctx, job.Timeout)
+	defer cancel()
+	taskCtx = util.WithProxyKey(taskCtx, job.DownloadContext.MatchedContentID)
+	logger := util.GetLogger(job.DownloadContext.Context).With("job_id", job.ID)
+	taskCtx = util.WithLogger(taskCtx, logger)
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("panic occurred while running job", "panic", r)
+			err = fmt.Errorf("panic occurred while running job: %v", r)
+		}
+	}()
+	return job.Run(taskCtx)
+}
+
+// GetInstanceID returns the name of this replica, from
+// INSTANCE_ID env or the hostname. it must be stable across
+// restarts for the replica to restore its own jobs
+func GetInstanceID() string {
+	if instanceID := os.Getenv("INSTANCE_ID"); instanceID != "" {
+		return instanceID
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return hostname
+}
+
+// isStored reports whether the job is requested with
+// a message, so it's stored until done
+func (job *Job) isStored() bool {
+	return job.URL != "" && job.Context != nil && job.Context.Message != nil
+}
+
+// persist stores jobs requested with a message
+func (job *Job) persist() {
+	if !job.isStored() {
+		return
+	}
+	ctx := job.Context
+	err := database.SavePendingJob(&models.PendingJob{
+		ID:        job.ID,
+		UserID:    job.UserID,
+		ChatID:    job.ChatID,
+		ChatType:  ctx.EffectiveChat.Type,
+		MessageID: ctx.EffectiveMessage.MessageId,
+		URL:       job.URL,
+		Instance:  GetInstanceID(),
+	})
+	if err != nil {
+		GetUpdateLogger(ctx).Error(
+			"failed to store job",
+			"job_id", job.ID,
+			"error", err,
+		)
+	}
+}
+
+func (job *Job) forget() {
+	if job.URL == "" {
+		return
+	}
+	err := database.DeletePendingJob(job.ID)
+	if err != nil {
+		GetUpdateLogger(job.Context).Error(
+			"failed to delete stored job",
+			"job_id", job.ID,
+			"error", err,
+		)
+	}
+}
+
+// resumeKey returns a key identifying the download
+// of the media at idx across restarts, if the job
+// carried by ctx is stored
+func resumeKey(ctx context.Context, idx int) string {
+	job, ok := ctx.Value(jobContextKey{}).(*Job)
+	if !ok || !job.isStored() {
+		return ""
+	}
+	return fmt.Sprintf("%s_%d", job.ID, idx)
+}
+
+func (job *Job) interrupt() {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.interrupted = true
+}
+
+func (job *Job) isInterrupted() bool {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.interrupted
+}
+
+// interruptError tells the user whether the
+// job will resume after the restart
+func (job *Job) interruptError() error {
+	if job.isStored() {
+		return util.ErrJobInterrupted
+	}
+	return util.ErrShuttingDown
+}
+
+func (job *Job) deleteQueueMessage() {
+	job.mu.Lock()
+	statusMessage := job.statusMessage
+	job.mu.Unlock()
+	if statusMessage != nil {
+		statusMessage.Delete(job.Bot, nil)
+	}
+}
+
+func (job *Job) sendQueueMessage(position int) {
+	ctx := job.Context
+	if ctx == nil || ctx.Message == nil {
+		// only direct messages get a queue message,
+		// other updates already show a loading state
+		return
+	}
+	msg, err := ctx.EffectiveMessage.Reply(
+		job.Bot,
+		fmt.Sprintf("your request is queued (position %d)", position),
+		&gotgbot.SendMessageOpts{
+			ReplyMarkup: CancelKeyboard(job.ID),
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.started || job.ctx.Err() != nil {
+		// the job started while the message was being sent
+		msg.Delete(job.Bot, nil)
+		return
+	}
+	job.statusMessage = msg
+}
+
+func compareJobs(a *Job, b *Job) int {
+	if a.Priority != b.Priority {
+		// higher priority first
+		return int(b.Priority - a.Priority)
+	}
+	return cmp.Compare(a.seq, b.seq)
+}
+
+func getQueueSize() int {
+	size, err := strconv.Atoi(os.Getenv("QUEUE_MAX_SIZE"))
+	if err != nil || size < 1 {
+		return defaultQueueSize
+	}
+	return size
+}
+
+func getExtractorConcurrency(codeName string) int {
+	cfg := config.GetExtractorConfig(codeName)
+	if cfg == nil {
+		return 0
+	}
+	return cfg.MaxConcurrency
+}
+
+// CancelKeyboard returns a keyboard
+// with a button to cancel the job
+func CancelKeyboard(jobID string) gotgbot.InlineKeyboardMarkup {
+	return gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{
+				{
+					Text:         "cancel",
+					CallbackData: "cancel:" + jobID,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"govd/database"
+	"govd/models"
+	"govd/util"
+)
+
+type tokenBucket struct {
+	mu        sync.Mutex
+	tokens    float64
+	updatedAt time.Time
+}
+
+var rateLimitBuckets sync.Map
+var rateLimitCleanupActive sync.Once
+
+const (
+	defaultUserRateLimit = 10
+	defaultChatRateLimit = 30
+	bucketIdleTimeout    = 10 * time.Minute
+)
+
+// CheckRateLimit returns a *util.RateLimitError if the user
+// or the chat exceeded its rate limit or daily quota.
+// settings is nil for private chats
+func CheckRateLimit(
+	userID int64,
+	chatID int64,
+	settings *models.GroupSettings,
+) error {
+	rateLimitCleanupActive.Do(startRateLimitCleanup)
+
+	if settings != nil {
+		limit := getEnvLimit("CHAT_RATE_LIMIT", defaultChatRateLimit)
+		if settings.RateLimit > 0 {
+			limit = settings.RateLimit
+		}
+		wait := takeToken(fmt.Sprintf("chat:%d", chatID), limit)
+		if wait > 0 {
+			return &util.RateLimitError{
+				Kind:       util.ErrChatRateLimited,
+				RetryAfter: wait,
+			}
+		}
+	}
+	limit := getEnvLimit("USER_RATE_LIMIT", defaultUserRateLimit)
+	wait := takeToken(fmt.Sprintf("user:%d", userID), limit)
+	if wait > 0 {
+		return &util.RateLimitError{
+			Kind:       util.ErrUserRateLimited,
+			RetryAfter: wait,
+		}
+	}
+	return CheckDailyQuota(userID, chatID, settings)
+}
+
+// CheckDailyQuota returns a *util.RateLimitError if the
+// user or the chat reached its daily quota, without
+// taking from the rate limits. settings is nil for
+// private chats
+func CheckDailyQuota(
+	userID int64,
+	chatID int64,
+	settings *models.GroupSettings,
+) error {
+	if settings != nil && settings.DailyLimit > 0 {
+		usage, err := database.GetDailyUsage(chatID)
+		if err != nil {
+			return fmt.Errorf("failed to get chat usage: %w", err)
+		}
+		if usage.Downloads >= settings.DailyLimit {
+			return &util.RateLimitError{
+				Kind:       util.ErrChatQuotaReached,
+				RetryAfter: database.GetUsageResetTime(),
+			}
+		}
+	}
+	downloadsLimit := getEnvLimit("USER_DAILY_DOWNLOADS", 0)
+	bytesLimit := int64(getEnvLimit("USER_DAILY_MB", 0)) * 1024 * 1024
+	if downloadsLimit > 0 || bytesLimit > 0 {
+		usage, err := database.GetDailyUsage(userID)
+		if err != nil {
+			return fmt.Errorf("failed to get user usage: %w", err)
+		}
+		if (downloadsLimit > 0 && usage.Downloads >= downloadsLimit) ||
+			(bytesLimit > 0 && usage.Bytes >= bytesLimit) {
+			return &util.RateLimitError{
+				Kind:       util.ErrUserQuotaReached,
+				RetryAfter: database.GetUsageResetTime(),
+			}
+		}
+	}
+	return nil
+}
+
+// RecordUsage adds a download to the
+// daily usage of the user and the chat
+func RecordUsage(
+	userID int64,
+	chatID int64,
+	settings *models.GroupSettings,
+	bytes int64,
+) {
+	err := database.AddDailyUsage(userID, 1, bytes)
+	if err != nil {
+		slog.Error("failed to record user usage", "user_id", userID, "error", err)
+	}
+	if settings == nil {
+		return
+	}
+	err = database.AddDailyUsage(chatID, 1, bytes)
+	if err != nil {
+		slog.Error("failed to record chat usage", "chat_id", chatID, "error", err)
+	}
+}
+
+// takeToken takes a token from the bucket of the given key,
+// refilled at limit tokens per minute. it returns how long
+// to wait for the next token, or 0 if a token was taken
+func takeToken(key string, limit int) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+	now := time.Now()
+	value, _ := rateLimitBuckets.LoadOrStore(key, &tokenBucket{
+		tokens:    float64(limit),
+		updatedAt: now,
+	})
+	bucket := value.(*tokenBucket)
+
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	rate := float64(limit) / time.Minute.Seconds()
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	bucket.tokens = min(float64(limit), bucket.tokens+elapsed*rate)
+	bucket.updatedAt = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0
+	}
+	missing := 1 - bucket.tokens
+	return time.Duration(missing / rate * float64(time.Second))
+}
+
+func getEnvLimit(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return fallback
+	}
+	return limit
+}
+
+func startRateLimitCleanup() {
+	go func() {
+		ticker := time.NewTicker(1 * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			now := time.Now()
+			rateLimitBuckets.Range(func(key, value any) bool {
+				bucket, ok := value.(*tokenBucket)
+				if !ok {
+					rateLimitBuckets.Delete(key)
+					return true
+				}
+				bucket.mu.Lock()
+				idle := now.Sub(bucket.updatedAt) > bucketIdleTimeout
+				bucket.mu.Unlock()
+				if idle {
+					rateLimitBuckets.Delete(key)
+				}
+				return true
+			})
+		}
+	}()
+}
@@ -0,0 +1,270 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"govd/enums"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+// StatusMessage shows the progress of a running task.
+// it is edited periodically to respect telegram limits,
+// and only sent if the task is not done within an interval
+type StatusMessage struct {
+	bot   *gotgbot.Bot
+	ctx   *ext.Context
+	jobID string
+
+	mu     sync.Mutex
+	header string
+	phase  enums.DownloadPhase
+	items  []*itemProgress
+
+	// only accessed by the render goroutine
+	// or after it has stopped
+	message  *gotgbot.Message
+	owned    bool
+	lastText string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type itemProgress struct {
+	progress  float64
+	bytes     int64
+	startedAt time.Time
+}
+
+type statusContextKey struct{}
+
+const statusUpdateInterval = 4 * time.Second
+
+// NewStatusMessage returns a status message for the given update.
+// message is an already sent message to reuse, if any.
+// the message shows a cancel button for the given job
+func NewStatusMessage(
+	bot *gotgbot.Bot,
+	ctx *ext.Context,
+	message *gotgbot.Message,
+	jobID string,
+) *StatusMessage {
+	status := &StatusMessage{
+		bot:   bot,
+		ctx:   ctx,
+		jobID: jobID,
+		phase: enums.DownloadPhaseExtracting,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	switch {
+	case message != nil:
+		status.message = message
+		status.owned = true
+	case ctx.CallbackQuery != nil:
+		// edit the message the button belongs to
+		status.message = ctx.EffectiveMessage
+	}
+	return status
+}
+
+// WithStatusMessage returns a copy of ctx carrying the status message
+func WithStatusMessage(
+	ctx context.Context,
+	status *StatusMessage,
+) context.Context {
+	return context.WithValue(ctx, statusContextKey{}, status)
+}
+
+// GetStatusMessage returns the status message carried by ctx, or nil.
+// all methods of StatusMessage are safe to call on nil
+func GetStatusMessage(ctx context.Context) *StatusMessage {
+	status, _ := ctx.Value(statusContextKey{}).(*StatusMessage)
+	return status
+}
+
+// Start periodically renders the status message
+// until Close or Finish are called
+func (status *StatusMessage) Start() {
+	go func() {
+		defer close(status.done)
+
+		ticker := time.NewTicker(statusUpdateInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-status.stop:
+				return
+			case <-ticker.C:
+				status.render(status.text(), true)
+			}
+		}
+	}()
+}
+
+// Close stops updating the status message
+// and deletes it if it was sent by the bot for this task
+func (status *StatusMessage) Close() {
+	if status == nil {
+		return
+	}
+	status.halt()
+	if status.owned && status.message != nil {
+		status.message.Delete(status.bot, nil)
+	}
+}
+
+// Finish stops updating the status message
+// and replaces it with the given text
+func (status *StatusMessage) Finish(text string) {
+	if status == nil {
+		return
+	}
+	status.halt()
+	status.render(text, false)
+	// keep the final text
+	status.owned = false
+}
+
+func (status *StatusMessage) SetHeader(header string) {
+	if status == nil {
+		return
+	}
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	status.header = header
+}
+
+func (status *StatusMessage) SetPhase(phase enums.DownloadPhase) {
+	if status == nil {
+		return
+	}
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	status.phase = phase
+}
+
+// SetItems resets the progress for the given number of items
+func (status *StatusMessage) SetItems(count int) {
+	if status == nil {
+		return
+	}
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	now := time.Now()
+	status.items = make([]*itemProgress, count)
+	for idx := range status.items {
+		status.items[idx] = &itemProgress{startedAt: now}
+	}
+}
+
+// ProgressUpdater returns a function reporting
+// the download progress of the item at idx
+func (status *StatusMessage) ProgressUpdater(idx int) func(float64, int64) {
+	if status == nil {
+		return nil
+	}
+	return func(progress float64, bytes int64) {
+		status.mu.Lock()
+		defer status.mu.Unlock()
+		if idx >= len(status.items) {
+			return
+		}
+		status.items[idx].progress = progress
+		status.items[idx].bytes = bytes
+	}
+}
+
+func (status *StatusMessage) halt() {
+	select {
+	case <-status.stop:
+	default:
+		close(status.stop)
+	}
+	<-status.done
+}
+
+func (status *StatusMessage) text() string {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+
+	var lines []string
+	if status.header != "" {
+		lines = append(lines, status.header)
+	}
+	phaseLine := string(status.phase) + "..."
+	isDownloading := status.phase == enums.DownloadPhaseDownloading
+	if isDownloading && len(status.items) == 1 {
+		phaseLine += " " + status.items[0].String()
+	}
+	lines = append(lines, phaseLine)
+	if isDownloading && len(status.items) > 1 {
+		for idx, item := range status.items {
+			lines = append(lines, fmt.Sprintf("%d. %s", idx+1, item))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (status *StatusMessage) render(text string, withKeyboard bool) {
+	if text == status.lastText {
+		return
+	}
+	var editOpts *gotgbot.EditMessageTextOpts
+	var sendOpts *gotgbot.SendMessageOpts
+	if withKeyboard && status.jobID != "" {
+		keyboard := CancelKeyboard(status.jobID)
+		editOpts = &gotgbot.EditMessageTextOpts{ReplyMarkup: keyboard}
+		sendOpts = &gotgbot.SendMessageOpts{ReplyMarkup: keyboard}
+	}
+	ctx := status.ctx
+	switch {
+	case status.message != nil:
+		_, _, err := status.message.EditText(status.bot, text, editOpts)
+		if err != nil {
+			return
+		}
+	case ctx.ChosenInlineResult != nil:
+		if editOpts == nil {
+			editOpts = &gotgbot.EditMessageTextOpts{}
+		}
+		editOpts.InlineMessageId = ctx.ChosenInlineResult.InlineMessageId
+		_, _, err := status.bot.EditMessageText(text, editOpts)
+		if err != nil {
+			return
+		}
+	case ctx.Message != nil:
+		msg, err := ctx.EffectiveMessage.Reply(status.bot, text, sendOpts)
+		if err != nil {
+			return
+		}
+		status.message = msg
+		status.owned = true
+	default:
+		return
+	}
+	status.lastText = text
+}
+
+func (item *itemProgress) String() string {
+	var text string
+	if item.progress < 0 {
+		// the total size is unknown
+		text = formatFileSize(item.bytes)
+	} else {
+		text = fmt.Sprintf("%d%%", int(item.progress*100))
+	}
+	elapsed := time.Since(item.startedAt).Seconds()
+	if item.bytes > 0 && elapsed > 0 {
+		speed := int64(float64(item.bytes) / elapsed)
+		text += fmt.Sprintf(" (%s/s)", formatFileSize(speed))
+	}
+	return text
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"govd/enums"
+	"govd/models"
+	"govd/util/av"
+)
+
+// TranscodeMedias converts videos telegram can't stream
+// (hevc, vp9, av1...) to H.264/AAC, so they are sent as
+// videos instead of documents. it only applies when the
+// chat enabled it and the media has no avc format at all
+func TranscodeMedias(
+	ctx context.Context,
+	dlCtx *models.DownloadContext,
+	medias []*models.DownloadedMedia,
+) error {
+	if !isTranscodeEnabled(dlCtx) {
+		return nil
+	}
+	status := GetStatusMessage(ctx)
+	for _, media := range medias {
+		if !needsTranscode(media.Media) {
+			continue
+		}
+		status.SetPhase(enums.DownloadPhaseTranscoding)
+		err := transcodeMedia(ctx, media)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func transcodeMedia(
+	ctx context.Context,
+	media *models.DownloadedMedia,
+) error {
+	outputPath := strings.TrimSuffix(
+		media.FilePath,
+		filepath.Ext(media.FilePath),
+	) + ".avc.mp4"
+	err := av.TranscodeToAVC(ctx, media.FilePath, outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to transcode video: %w", err)
+	}
+	os.Remove(media.FilePath)
+	media.FilePath = outputPath
+
+	format := media.Media.Format
+	format.VideoCodec = enums.MediaCodecAVC
+	if format.AudioCodec != "" {
+		format.AudioCodec = enums.MediaCodecAAC
+	}
+	return nil
+}
+
+func needsTranscode(media *models.Media) bool {
+	format := media.Format
+	if format == nil || format.Type != enums.MediaTypeVideo {
+		return false
+	}
+	switch format.VideoCodec {
+	case enums.MediaCodecHEVC,
+		enums.MediaCodecVP9,
+		enums.MediaCodecVP8,
+		enums.MediaCodecAV1:
+	default:
+		return false
+	}
+	// the user can choose the avc format instead
+	for _, other := range media.Formats {
+		if other.VideoCodec == enums.MediaCodecAVC {
+			return false
+		}
+	}
+	return true
+}
+
+// isTranscodeEnabled checks the group setting, or
+// the TRANSCODE_VIDEOS env for private chats and inline
+func isTranscodeEnabled(dlCtx *models.DownloadContext) bool {
+	if dlCtx.GroupSettings != nil {
+		return dlCtx.GroupSettings.Transcode != nil &&
+			*dlCtx.GroupSettings.Transcode
+	}
+	enabled, err := strconv.ParseBool(os.Getenv("TRANSCODE_VIDEOS"))
+	if err != nil {
+		return false
+	}
+	return enabled
+}
@@ -0,0 +1,239 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"govd/enums"
+	"govd/models"
+	"govd/util"
+	"govd/util/av"
+)
+
+const defaultMaxUploadSize = 50 // MB
+
+// FitUploadLimit makes every media fit the upload limit.
+// medias too large are replaced with a smaller format,
+// compressed, or split into parts, in this order.
+// it returns the medias to send and a note for each change
+func FitUploadLimit(
+	ctx context.Context,
+	medias []*models.DownloadedMedia,
+) ([]*models.DownloadedMedia, []string, error) {
+	limit := GetMaxUploadSize()
+	result := make([]*models.DownloadedMedia, 0, len(medias))
+	var notes []string
+
+	for idx, media := range medias {
+		fitted, note, err := fitMedia(ctx, media, limit)
+		if err != nil {
+			// files of the next medias are not
+			// owned by anyone else, remove them
+			for _, media := range medias[idx+1:] {
+				removeDownloadedMedia(media)
+			}
+			for _, media := range result {
+				removeDownloadedMedia(media)
+			}
+			return nil, nil, err
+		}
+		result = append(result, fitted...)
+		if note != "" {
+			notes = append(notes, note)
+		}
+	}
+	return result, notes, nil
+}
+
+func GetMaxUploadSize() int64 {
+	size := getEnvLimit("MAX_UPLOAD_SIZE", defaultMaxUploadSize)
+	if size < 1 {
+		size = defaultMaxUploadSize
+	}
+	return int64(size) * 1024 * 1024
+}
+
+func fitMedia(
+	ctx context.Context,
+	media *models.DownloadedMedia,
+	limit int64,
+) ([]*models.DownloadedMedia, string, error) {
+	size, err := getFileSize(media.FilePath)
+	if err != nil {
+		removeDownloadedMedia(media)
+		return nil, "", err
+	}
+	format := media.Media.Format
+	if size <= limit || format.Type == enums.MediaTypePhoto {
+		return []*models.DownloadedMedia{media}, "", nil
+	}
+	status := GetStatusMessage(ctx)
+
+	smaller := getSmallerFormat(media.Media, limit)
+	if smaller != nil {
+		replacement, err := downloadReplacementFormat(ctx, media, smaller)
+		if err != nil && ctx.Err() != nil {
+			removeDownloadedMedia(media)
+			return nil, "", ctx.Err()
+		}
+		if err == nil {
+			replacementSize, err := getFileSize(replacement.FilePath)
+			if err == nil && replacementSize <= limit {
+				removeDownloadedMedia(media)
+				// stored in place of the default format,
+				// so the next requests use the cache
+				smaller.IsDefault = format.IsDefault
+				return []*models.DownloadedMedia{replacement}, fmt.Sprintf(
+					"sent in lower quality (%s) to fit the upload limit",
+					FormatButtonText(smaller),
+				), nil
+			}
+			removeDownloadedMedia(replacement)
+		}
+		// restore the original format
+		media.Media.Format = format
+	}
+
+	duration := format.Duration
+	if duration <= 0 {
+		duration = av.GetDuration(media.FilePath)
+	}
+	if duration <= 0 {
+		removeDownloadedMedia(media)
+		return nil, "", util.ErrFileTooLarge
+	}
+
+	status.SetPhase(enums.DownloadPhaseCompressing)
+	if format.Type == enums.MediaTypeVideo {
+		outputPath := strings.TrimSuffix(
+			media.FilePath,
+			filepath.Ext(media.FilePath),
+		) + ".compressed.mp4"
+		err := av.CompressVideo(
+			ctx, media.FilePath, outputPath,
+			limit*95/100, duration,
+		)
+		if err != nil && ctx.Err() != nil {
+			removeDownloadedMedia(media)
+			return nil, "", ctx.Err()
+		}
+		if err == nil {
+			compressedSize, err := getFileSize(outputPath)
+			if err == nil && compressedSize <= limit {
+				os.Remove(media.FilePath)
+				media.FilePath = outputPath
+				format.VideoCodec = enums.MediaCodecAVC
+				format.AudioCodec = enums.MediaCodecAAC
+				return []*models.DownloadedMedia{media}, "compressed to fit the upload limit", nil
+			}
+			os.Remove(outputPath)
+		}
+	}
+
+	// keep parts a bit below the limit, since
+	// they are cut on keyframes
+	partDuration := duration * (limit * 90 / 100) / size
+	parts, err := av.SplitFile(ctx, media.FilePath, partDuration)
+	if err != nil {
+		removeDownloadedMedia(media)
+		return nil, "", fmt.Errorf("%w: %w", util.ErrFileTooLarge, err)
+	}
+	os.Remove(media.FilePath)
+
+	result := make([]*models.DownloadedMedia, 0, len(parts))
+	for idx, part := range parts {
+		partSize, err := getFileSize(part)
+		if err != nil || partSize > limit {
+			for _, part := range parts {
+				os.Remove(part)
+			}
+			if media.ThumbnailFilePath != "" {
+				os.Remove(media.ThumbnailFilePath)
+			}
+			return nil, "", util.ErrFileTooLarge
+		}
+		partFormat := *format
+		partFormat.Duration = av.GetDuration(part)
+		partMedia := *media.Media
+		partMedia.Format = &partFormat
+
+		var thumbnailFilePath string
+		if idx == 0 {
+			// the thumbnail is removed after sending,
+			// so it can only belong to one part
+			thumbnailFilePath = media.ThumbnailFilePath
+		}
+		result = append(result, &models.DownloadedMedia{
+			FilePath:          part,
+			ThumbnailFilePath: thumbnailFilePath,
+			Media:             &partMedia,
+			Index:             media.Index,
+		})
+	}
+	return result, fmt.Sprintf("split into %d parts to fit the upload limit", len(parts)), nil
+}
+
+// getSmallerFormat returns the best format of the
+// same type whose estimated size fits the limit
+func getSmallerFormat(
+	media *models.Media,
+	limit int64,
+) *models.MediaFormat {
+	current := media.Format
+	var best *models.MediaFormat
+	var bestSize int64
+	for _, format := range media.Formats {
+		if format.Type != current.Type ||
+			format.FormatID == current.FormatID ||
+			len(format.URL) == 0 {
+			continue
+		}
+		size := format.Bitrate * format.Duration / 8
+		if size <= 0 || size > limit*90/100 {
+			continue
+		}
+		if size > bestSize {
+			best = format
+			bestSize = size
+		}
+	}
+	return best
+}
+
+func downloadReplacementFormat(
+	ctx context.Context,
+	media *models.DownloadedMedia,
+	format *models.MediaFormat,
+) (*models.DownloadedMedia, error) {
+	SetMediaFormat(media.Media, format)
+	replacement, err := downloadMediaItem(ctx, media.Media, nil, media.Index)
+	if err != nil {
+		return nil, err
+	}
+	err = RunPlugins(ctx, []*models.DownloadedMedia{replacement})
+	if err != nil {
+		removeDownloadedMedia(replacement)
+		return nil, err
+	}
+	return replacement, nil
+}
+
+func removeDownloadedMedia(media *models.DownloadedMedia) {
+	if media.FilePath != "" {
+		os.Remove(media.FilePath)
+	}
+	if media.ThumbnailFilePath != "" {
+		os.Remove(media.ThumbnailFilePath)
+	}
+}
+
+func getFileSize(filePath string) (int64, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return info.Size(), nil
+}
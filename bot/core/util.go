@@ -22,6 +22,7 @@ func getFileThumbnail(
 	ctx context.Context,
 	format *models.MediaFormat,
 	filePath string,
+	config *models.DownloadConfig,
 ) (string, error) {
 	fileDir := filepath.Dir(filePath)
 	fileName := filepath.Base(filePath)
@@ -30,7 +31,7 @@ func getFileThumbnail(
 	thumbnailFilePath := filepath.Join(fileDir, fileBaseName+".thumb.jpeg")
 
 	if len(format.Thumbnail) > 0 {
-		file, err := util.DownloadFileInMemory(ctx, format.Thumbnail, nil)
+		file, err := util.DownloadFileInMemory(ctx, format.Thumbnail, config)
 		if err != nil {
 			return "", fmt.Errorf("failed to download file in memory: %w", err)
 		}
@@ -194,30 +195,47 @@ func HandleErrorMessage(
 	ctx *ext.Context,
 	err error,
 ) {
-	currentError := err
+	languageCode := getLanguageCode(ctx)
 
-	if errors.Is(currentError, context.Canceled) ||
-		errors.Is(currentError, context.DeadlineExceeded) {
+	if errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
 		SendErrorMessage(
 			bot, ctx,
-			"download request canceled or timed out",
+			translate(languageCode, canceledKey, "download request canceled or timed out"),
 		)
 		return
 	}
 
-	for currentError != nil {
-		var botError *util.Error
-		if errors.As(currentError, &botError) {
-			SendErrorMessage(bot, ctx,
-				"error occurred when downloading: "+currentError.Error(),
-			)
-			return
-		}
-		currentError = errors.Unwrap(currentError)
+	var rateLimitError *util.RateLimitError
+	if errors.As(err, &rateLimitError) {
+		SendErrorMessage(bot, ctx, localizeRateLimitError(ctx, rateLimitError))
+		return
+	}
+
+	errorPrefix := translate(languageCode, downloadErrorKey, "error occurred when downloading: ")
+
+	var extractorError *util.ExtractorError
+	if errors.As(err, &extractorError) {
+		logError(ctx, extractorError.Kind.Code, err)
+		SendErrorMessage(bot, ctx, errorPrefix+localizeError(ctx, extractorError.Kind))
+		return
+	}
+
+	var botError *util.Error
+	if errors.As(err, &botError) {
+		// expected errors, like rate limits
+		GetUpdateLogger(ctx).Debug(
+			"request failed",
+			"kind", botError.Code,
+			"error", err,
+		)
+		SendErrorMessage(bot, ctx, errorPrefix+localizeError(ctx, botError))
+		return
 	}
 
+	logError(ctx, "unknown", err)
 	lastError := util.GetLastError(err)
-	errorMessage := "error occurred when downloading: " + lastError.Error()
+	errorMessage := errorPrefix + lastError.Error()
 
 	if strings.Contains(errorMessage, bot.Token) {
 		errorMessage = "telegram related error, probably connection issue"
@@ -226,6 +244,20 @@ func HandleErrorMessage(
 	SendErrorMessage(bot, ctx, errorMessage)
 }
 
+// logError logs the cause of a failed download with the
+// kind of the failure, using the logger of the update
+func logError(
+	ctx *ext.Context,
+	kind string,
+	err error,
+) {
+	GetUpdateLogger(ctx).Error(
+		"download failed",
+		"kind", kind,
+		"error", err,
+	)
+}
+
 func SendErrorMessage(
 	bot *gotgbot.Bot,
 	ctx *ext.Context,
@@ -256,6 +288,12 @@ func SendErrorMessage(
 			&gotgbot.EditMessageTextOpts{
 				InlineMessageId: ctx.ChosenInlineResult.InlineMessageId,
 			})
+	case ctx.Update.CallbackQuery != nil:
+		ctx.EffectiveMessage.EditText(
+			bot,
+			errorMessage,
+			nil,
+		)
 	}
 }
 
@@ -277,3 +315,43 @@ func ensureMergeFormats(
 	videoFormat.AudioCodec = audioFormat.AudioCodec
 	videoFormat.Plugins = append(videoFormat.Plugins, plugins.MergeAudio)
 }
+
+// SetMediaFormat selects the format to download,
+// pairing it with an audio format if needed
+func SetMediaFormat(
+	media *models.Media,
+	format *models.MediaFormat,
+) {
+	// ensure we can merge video and audio formats
+	ensureMergeFormats(media, format)
+	media.Format = format
+}
+
+func hasPlugins(medias []*models.DownloadedMedia) bool {
+	for _, media := range medias {
+		if len(media.Media.Format.Plugins) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RunPlugins runs the plugins of each downloaded media.
+// plugins act as post-processing for the media.
+// they are run after the media is downloaded
+// and before it is sent to the user
+// this allows for things like merging audio and video, etc.
+func RunPlugins(
+	ctx context.Context,
+	medias []*models.DownloadedMedia,
+) error {
+	for _, media := range medias {
+		for _, plugin := range media.Media.Format.Plugins {
+			err := plugin(ctx, media)
+			if err != nil {
+				return fmt.Errorf("failed to run plugin: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"govd/bot/core"
+	"govd/util"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+func CancelHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
+	jobs := core.GetUserJobs(
+		ctx.EffectiveUser.Id,
+		ctx.EffectiveChat.Id,
+	)
+	if len(jobs) == 0 {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			"you have no downloads to cancel",
+			nil,
+		)
+		return nil
+	}
+	for _, job := range jobs {
+		job.Cancel()
+	}
+	ctx.EffectiveMessage.Reply(
+		bot,
+		fmt.Sprintf("canceled %d download(s)", len(jobs)),
+		nil,
+	)
+	return nil
+}
+
+func CancelButtonHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
+	// cancel:<job id>
+	jobID := strings.TrimPrefix(ctx.CallbackQuery.Data, "cancel:")
+	job, ok := core.GetJob(jobID)
+	if !ok {
+		ctx.CallbackQuery.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{
+			Text:      "this download is already over",
+			ShowAlert: true,
+		})
+		return nil
+	}
+	userID := ctx.CallbackQuery.From.Id
+	if job.UserID != userID {
+		// group admins can cancel any download
+		if job.ChatID == 0 || !util.IsUserAdmin(bot, job.ChatID, userID) {
+			ctx.CallbackQuery.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{
+				Text:      "this is not for you",
+				ShowAlert: true,
+			})
+			return nil
+		}
+	}
+	job.Cancel()
+	ctx.CallbackQuery.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{
+		Text: "download canceled",
+	})
+	return nil
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"govd/util"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+func CookiesHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
+	if !util.IsBotAdmin(ctx.EffectiveUser.Id) {
+		return nil
+	}
+	stats := util.GetCookieJarStats()
+	if len(stats) == 0 {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			"no cookie jars used yet",
+			nil,
+		)
+		return nil
+	}
+	var builder strings.Builder
+	builder.WriteString("cookie jars:\n")
+	for _, jar := range stats {
+		status := "ok"
+		if remaining := time.Until(jar.QuarantinedUntil); remaining > 0 {
+			status = fmt.Sprintf(
+				"quarantined for %s (%s)",
+				remaining.Round(time.Minute),
+				jar.LastError,
+			)
+		}
+		fmt.Fprintf(
+			&builder,
+			"- %s: %s, %d uses, %d failures\n",
+			jar.FileName,
+			status,
+			jar.Uses,
+			jar.Failures,
+		)
+	}
+	ctx.EffectiveMessage.Reply(
+		bot,
+		builder.String(),
+		nil,
+	)
+	return nil
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"govd/bot/core"
+	"govd/database"
+	"govd/util"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+func FormatsHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
+	args := ctx.Args()
+	if len(args) != 2 {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			"usage: /formats (url)",
+			nil,
+		)
+		return nil
+	}
+	dlCtx, err := matchURL(ctx, args[1])
+	if err != nil {
+		core.HandleErrorMessage(
+			bot, ctx, err)
+		return nil
+	}
+	if dlCtx == nil || dlCtx.Extractor == nil {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			"unsupported url",
+			nil,
+		)
+		return nil
+	}
+	userID := ctx.EffectiveMessage.From.Id
+	if ctx.EffectiveMessage.Chat.Type != "private" {
+		settings, err := database.GetGroupSettings(ctx.EffectiveMessage.Chat.Id)
+		if err != nil {
+			return err
+		}
+		dlCtx.GroupSettings = settings
+	}
+	if userID != 1087968824 {
+		// groupAnonymousBot
+		_, err = database.GetUser(userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	core.TypingEffect(bot, ctx.EffectiveMessage.Chat.Id)
+	err = core.HandleFormatsList(bot, ctx, dlCtx)
+	if err != nil {
+		core.HandleErrorMessage(
+			bot, ctx, err)
+	}
+	return nil
+}
+
+func FormatSelectHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
+	// format:<task id>:<format index>
+	data := strings.Split(ctx.CallbackQuery.Data, ":")
+	if len(data) != 3 {
+		return nil
+	}
+	taskID := data[1]
+	idx, err := strconv.Atoi(data[2])
+	if err != nil {
+		return nil
+	}
+
+	task, ok := core.GetFormatsTask(taskID)
+	if !ok {
+		ctx.CallbackQuery.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{
+			Text:      util.ErrFormatsTaskExpired.Message,
+			ShowAlert: true,
+		})
+		return nil
+	}
+	if task.UserID != ctx.CallbackQuery.From.Id {
+		ctx.CallbackQuery.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{
+			Text:      "this is not for you",
+			ShowAlert: true,
+		})
+		return nil
+	}
+	if idx < 0 || idx >= len(task.Formats) {
+		return nil
+	}
+	err = core.CheckRateLimit(
+		task.UserID,
+		ctx.EffectiveChat.Id,
+		task.DownloadContext.GroupSettings,
+	)
+	if err != nil {
+		ctx.CallbackQuery.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{
+			Text:      err.Error(),
+			ShowAlert: true,
+		})
+		return nil
+	}
+	core.DeleteFormatsTask(taskID)
+	ctx.CallbackQuery.Answer(bot, nil)
+
+	ctx.EffectiveMessage.EditText(
+		bot,
+		"downloading...",
+		nil,
+	)
+
+	job := core.NewJob(
+		bot, ctx, task.DownloadContext,
+		10*time.Minute,
+		func(taskCtx context.Context) error {
+			err := core.HandleFormatDownload(
+				bot, ctx, taskCtx,
+				task, task.Formats[idx],
+			)
+			if err != nil {
+				return err
+			}
+			ctx.EffectiveMessage.Delete(bot, nil)
+			return nil
+		},
+	)
+	err = core.EnqueueJob(job)
+	if err != nil {
+		core.HandleErrorMessage(
+			bot, ctx, err)
+	}
+	return nil
+}
@@ -11,12 +11,19 @@ var helpMessage = "usage:\n" +
 	"- you can send a link to the bot privately " +
 	"to download the media too\n" +
 	"- you can use inline mode " +
-	"to download media from any chat\n\n" +
+	"to download media from any chat\n" +
+	"- you can use /formats (url) " +
+	"to choose the format to download\n" +
+	"- you can use /cancel " +
+	"to stop your running downloads\n\n" +
 	"group commands:\n" +
 	"- /settings = show current settings\n" +
 	"- /captions (true|false) = enable/disable descriptions\n" +
+	"- /transcode (true|false) = convert videos that can't be played inline\n" +
 	"- /nsfw (true|false) = enable/disable nsfw content\n" +
-	"- /limit (int) = set max items in media groups\n\n" +
+	"- /limit (int) = set max items in media groups\n" +
+	"- /ratelimit (int) = set max requests per minute (0 = default)\n" +
+	"- /dailylimit (int) = set max downloads per day (0 = unlimited)\n\n" +
 	"note: the bot is still in beta, " +
 	"so expect some bugs and missing features.\n"
 
@@ -8,8 +8,6 @@ import (
 	"strings"
 	"time"
 
-	extractors "govd/ext"
-
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 )
@@ -26,7 +24,7 @@ func InlineDownloadHandler(
 		})
 		return nil
 	}
-	dlCtx, err := extractors.CtxByURL(url)
+	dlCtx, err := matchURL(ctx, url)
 	if err != nil || dlCtx == nil || dlCtx.Extractor == nil {
 		ctx.InlineQuery.Answer(bot, []gotgbot.InlineQueryResult{}, &gotgbot.AnswerInlineQueryOpts{
 			CacheTime:  1,
@@ -48,41 +46,43 @@ func InlineDownloadResultHandler(
 		return nil
 	}
 	defer core.DeleteTask(taskID)
+	// keep the request ID of the inline query
+	core.SetUpdateLogger(ctx, util.GetLogger(dlCtx.Context))
 
-	mediaChan := make(chan *models.Media, 1)
-	errChan := make(chan error, 1)
-	timeout, cancel := context.WithTimeout(
-		context.Background(),
-		5*time.Minute,
-	)
-	defer cancel()
+	userID := ctx.EffectiveUser.Id
+	err := core.CheckRateLimit(userID, userID, nil)
+	if err != nil {
+		core.HandleErrorMessage(bot, ctx, err)
+		return nil
+	}
 
-	taskCtx, cancel := context.WithTimeout(
-		context.Background(),
+	job := core.NewJob(
+		bot, ctx, dlCtx,
 		5*time.Minute,
-	)
-	defer cancel()
+		func(taskCtx context.Context) error {
+			mediaChan := make(chan *models.Media, 1)
+			errChan := make(chan error, 1)
 
-	go core.GetInlineFormat(
-		taskCtx,
-		bot, ctx, dlCtx,
-		mediaChan, errChan,
+			go core.GetInlineFormat(
+				taskCtx,
+				bot, ctx, dlCtx,
+				mediaChan, errChan,
+			)
+			select {
+			case media := <-mediaChan:
+				return core.HandleInlineCachedResult(
+					bot, ctx, media,
+				)
+			case err := <-errChan:
+				return err
+			case <-taskCtx.Done():
+				return util.ErrTimeout
+			}
+		},
 	)
-	select {
-	case media := <-mediaChan:
-		err := core.HandleInlineCachedResult(
-			bot, ctx, media,
-		)
-		if err != nil {
-			core.HandleErrorMessage(bot, ctx, err)
-			return nil
-		}
-	case err := <-errChan:
+	err = core.EnqueueJob(job)
+	if err != nil {
 		core.HandleErrorMessage(bot, ctx, err)
-		return nil
-	case <-timeout.Done():
-		core.HandleErrorMessage(bot, ctx, util.ErrTimeout)
-		return nil
 	}
 	return nil
 }
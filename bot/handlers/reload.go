@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"fmt"
+	"govd/util"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+func ReloadHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
+	if !util.IsBotAdmin(ctx.EffectiveUser.Id) {
+		return nil
+	}
+	changed, err := util.ReloadConfigs()
+	if err != nil {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			fmt.Sprintf("reload failed, keeping current configs: %v", err),
+			nil,
+		)
+		return nil
+	}
+	if len(changed) == 0 {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			"configs reloaded, no extractor changed",
+			nil,
+		)
+		return nil
+	}
+	ctx.EffectiveMessage.Reply(
+		bot,
+		"configs reloaded, changed: "+strings.Join(changed, ", "),
+		nil,
+	)
+	return nil
+}
@@ -27,10 +27,13 @@ func SettingsHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
 	ctx.EffectiveMessage.Reply(
 		bot,
 		fmt.Sprintf(
-			"settings for this group\n\ncaptions: %s\nnsfw: %s\nmedia group limit: %d",
+			"settings for this group\n\ncaptions: %s\ntranscode: %s\nnsfw: %s\nmedia group limit: %d\nrate limit: %s\ndaily limit: %s",
 			strconv.FormatBool(*settings.Captions),
+			strconv.FormatBool(*settings.Transcode),
 			strconv.FormatBool(*settings.NSFW),
 			settings.MediaGroupLimit,
+			formatLimitSetting(settings.RateLimit, "default", "/min"),
+			formatLimitSetting(settings.DailyLimit, "unlimited", "/day"),
 		),
 		nil,
 	)
@@ -95,6 +98,64 @@ func CaptionsHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
 	return nil
 }
 
+func TranscodeHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveMessage.Chat.Type == "private" {
+		return nil
+	}
+
+	chatID := ctx.EffectiveMessage.Chat.Id
+	userID := ctx.EffectiveMessage.From.Id
+
+	args := ctx.Args()
+	if len(args) != 2 {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			"usage: /transcode (true|false)",
+			nil,
+		)
+		return nil
+	}
+	if !util.IsUserAdmin(bot, chatID, userID) {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			"you don't have permission to change settings",
+			nil,
+		)
+		return nil
+	}
+	userInput := strings.ToLower(args[1])
+	value, err := strconv.ParseBool(userInput)
+	if err != nil {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			fmt.Sprintf("invalid value (%s), use true or false", userInput),
+			nil,
+		)
+		return nil
+	}
+	settings, err := database.GetGroupSettings(chatID)
+	if err != nil {
+		return err
+	}
+	settings.Transcode = &value
+	err = database.UpdateGroupSettings(chatID, settings)
+	if err != nil {
+		return err
+	}
+	var message string
+	if value {
+		message = "transcoding enabled"
+	} else {
+		message = "transcoding disabled"
+	}
+	ctx.EffectiveMessage.Reply(
+		bot,
+		message,
+		nil,
+	)
+	return nil
+}
+
 func NSFWHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
 	if ctx.EffectiveMessage.Chat.Type == "private" {
 		return nil
@@ -211,3 +272,136 @@ func MediaGroupLimitHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
 	)
 	return nil
 }
+
+func RateLimitHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveMessage.Chat.Type == "private" {
+		return nil
+	}
+
+	chatID := ctx.EffectiveMessage.Chat.Id
+	userID := ctx.EffectiveMessage.From.Id
+
+	args := ctx.Args()
+	if len(args) != 2 {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			"usage: /ratelimit (int)",
+			nil,
+		)
+		return nil
+	}
+	if !util.IsUserAdmin(bot, chatID, userID) {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			"you don't have permission to change settings",
+			nil,
+		)
+		return nil
+	}
+	value, err := strconv.Atoi(args[1])
+	if err != nil {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			fmt.Sprintf("invalid value (%s), use a number", args[1]),
+			nil,
+		)
+		return nil
+	}
+	if value < 0 || value > 60 {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			"rate limit must be between 0 and 60 (0 = default)",
+			nil,
+		)
+		return nil
+	}
+	// ensure the settings row exists
+	_, err = database.GetGroupSettings(chatID)
+	if err != nil {
+		return err
+	}
+	// zero values are skipped by Updates
+	err = database.UpdateGroupSetting(chatID, "rate_limit", value)
+	if err != nil {
+		return err
+	}
+	ctx.EffectiveMessage.Reply(
+		bot,
+		fmt.Sprintf(
+			"rate limit set to %s",
+			formatLimitSetting(value, "default", " requests per minute"),
+		),
+		nil,
+	)
+	return nil
+}
+
+func DailyLimitHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveMessage.Chat.Type == "private" {
+		return nil
+	}
+
+	chatID := ctx.EffectiveMessage.Chat.Id
+	userID := ctx.EffectiveMessage.From.Id
+
+	args := ctx.Args()
+	if len(args) != 2 {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			"usage: /dailylimit (int)",
+			nil,
+		)
+		return nil
+	}
+	if !util.IsUserAdmin(bot, chatID, userID) {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			"you don't have permission to change settings",
+			nil,
+		)
+		return nil
+	}
+	value, err := strconv.Atoi(args[1])
+	if err != nil {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			fmt.Sprintf("invalid value (%s), use a number", args[1]),
+			nil,
+		)
+		return nil
+	}
+	if value < 0 || value > 10000 {
+		ctx.EffectiveMessage.Reply(
+			bot,
+			"daily limit must be between 0 and 10000 (0 = unlimited)",
+			nil,
+		)
+		return nil
+	}
+	// ensure the settings row exists
+	_, err = database.GetGroupSettings(chatID)
+	if err != nil {
+		return err
+	}
+	// zero values are skipped by Updates
+	err = database.UpdateGroupSetting(chatID, "daily_limit", value)
+	if err != nil {
+		return err
+	}
+	ctx.EffectiveMessage.Reply(
+		bot,
+		fmt.Sprintf(
+			"daily limit set to %s",
+			formatLimitSetting(value, "unlimited", " downloads per day"),
+		),
+		nil,
+	)
+	return nil
+}
+
+func formatLimitSetting(value int, zeroText string, unit string) string {
+	if value == 0 {
+		return zeroText
+	}
+	return strconv.Itoa(value) + unit
+}
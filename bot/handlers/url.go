@@ -5,11 +5,15 @@ import (
 	"govd/bot/core"
 	"govd/database"
 	extractors "govd/ext"
+	"govd/models"
+	"govd/util"
+	"log/slog"
 	"time"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/message"
+	"github.com/pkg/errors"
 )
 
 func URLHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
@@ -17,7 +21,7 @@ func URLHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
 	if messageURL == "" {
 		return nil
 	}
-	dlCtx, err := extractors.CtxByURL(messageURL)
+	dlCtx, err := matchURL(ctx, messageURL)
 	if err != nil {
 		core.HandleErrorMessage(
 			bot, ctx, err)
@@ -42,11 +46,18 @@ func URLHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
 		}
 	}
 
-	taskCtx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
+	err = core.CheckRateLimit(
+		userID,
+		ctx.EffectiveMessage.Chat.Id,
+		dlCtx.GroupSettings,
+	)
+	if err != nil {
+		core.HandleErrorMessage(
+			bot, ctx, err)
+		return nil
+	}
 
-	err = core.HandleDownloadRequest(
-		bot, ctx, taskCtx, dlCtx)
+	err = core.EnqueueJob(newURLJob(bot, ctx, dlCtx, messageURL))
 	if err != nil {
 		core.HandleErrorMessage(
 			bot, ctx, err)
@@ -54,6 +65,127 @@ func URLHandler(bot *gotgbot.Bot, ctx *ext.Context) error {
 	return nil
 }
 
+// RestoreJobs queues again the downloads
+// that were interrupted by a restart
+func RestoreJobs(bot *gotgbot.Bot) {
+	pendingJobs, err := database.GetPendingJobs(core.GetInstanceID())
+	if err != nil {
+		slog.Error("failed to get stored jobs", "error", err)
+		return
+	}
+	var restored int
+	for _, pending := range pendingJobs {
+		logger := slog.Default().With(
+			"request_id", util.RandomBase64(8),
+			"chat_id", pending.ChatID,
+			"user_id", pending.UserID,
+		)
+		if time.Since(pending.CreatedAt) > util.ResumableDownloadTTL {
+			logger.Info("stored job expired", "job_id", pending.ID)
+			database.DeletePendingJob(pending.ID)
+			continue
+		}
+		err := restoreJob(bot, pending, logger)
+		if errors.Is(err, util.ErrShuttingDown) {
+			// kept for the next start
+			break
+		}
+		if err != nil {
+			logger.Error(
+				"failed to restore job",
+				"job_id", pending.ID,
+				"url", pending.URL,
+				"error", err,
+			)
+			database.DeletePendingJob(pending.ID)
+			continue
+		}
+		restored++
+	}
+	if restored > 0 {
+		slog.Info("restored interrupted jobs", "count", restored)
+	}
+}
+
+func restoreJob(
+	bot *gotgbot.Bot,
+	pending *models.PendingJob,
+	logger *slog.Logger,
+) error {
+	dlCtx, err := extractors.CtxByURL(
+		util.WithLogger(context.Background(), logger),
+		pending.URL,
+	)
+	if err != nil {
+		return err
+	}
+	if dlCtx == nil || dlCtx.Extractor == nil {
+		return errors.New("no extractor found")
+	}
+	if pending.ChatType != "private" {
+		settings, err := database.GetGroupSettings(pending.ChatID)
+		if err != nil {
+			return err
+		}
+		dlCtx.GroupSettings = settings
+	}
+	// replies are sent to the original message
+	msg := &gotgbot.Message{
+		MessageId: pending.MessageID,
+		Chat: gotgbot.Chat{
+			Id:   pending.ChatID,
+			Type: pending.ChatType,
+		},
+		From: &gotgbot.User{Id: pending.UserID},
+		Text: pending.URL,
+	}
+	ctx := &ext.Context{
+		Update:           &gotgbot.Update{Message: msg},
+		EffectiveMessage: msg,
+		EffectiveChat:    &msg.Chat,
+		EffectiveUser:    msg.From,
+		EffectiveSender:  &gotgbot.Sender{User: msg.From},
+		Data:             make(map[string]any),
+	}
+	core.SetUpdateLogger(ctx, util.GetLogger(dlCtx.Context))
+	job := newURLJob(bot, ctx, dlCtx, pending.URL)
+	// the ID is kept to resume partial downloads
+	job.ID = pending.ID
+	return core.EnqueueJob(job)
+}
+
+func newURLJob(
+	bot *gotgbot.Bot,
+	ctx *ext.Context,
+	dlCtx *models.DownloadContext,
+	messageURL string,
+) *core.Job {
+	job := core.NewJob(
+		bot, ctx, dlCtx,
+		10*time.Minute,
+		func(taskCtx context.Context) error {
+			return core.HandleDownloadRequest(
+				bot, ctx, taskCtx, dlCtx)
+		},
+	)
+	job.URL = messageURL
+	return job
+}
+
+// matchURL matches the url with an extractor. the logger
+// of the update gets the extractor and the content ID
+func matchURL(
+	ctx *ext.Context,
+	url string,
+) (*models.DownloadContext, error) {
+	dlCtx, err := extractors.CtxByURL(core.UpdateContext(ctx), url)
+	if err != nil || dlCtx == nil || dlCtx.Extractor == nil {
+		return dlCtx, err
+	}
+	core.SetUpdateLogger(ctx, util.GetLogger(dlCtx.Context))
+	return dlCtx, nil
+}
+
 func URLFilter(msg *gotgbot.Message) bool {
 	return message.Text(msg) &&
 		!message.Command(msg) &&
@@ -1,13 +1,17 @@
 package bot
 
 import (
+	"context"
 	"log"
+	"log/slog"
 	"os"
 	"runtime/debug"
 	"strconv"
-	"time"
+	"sync/atomic"
 
+	"govd/bot/core"
 	botHandlers "govd/bot/handlers"
+	"govd/util/metrics"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
@@ -17,6 +21,9 @@ import (
 	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/inlinequery"
 )
 
+// set once the bot starts receiving updates
+var currentUpdater atomic.Pointer[ext.Updater]
+
 var allowedUpdates = []string{
 	"message",
 	"callback_query",
@@ -45,37 +52,64 @@ func Start() {
 		log.Println("failed to parse LOG_DISPATCHER_ERRORS env, using false")
 		logDispatcherErrors = false
 	}
+	// dispatcher errors are only visible
+	// with debug level, unless enabled
+	dispatcherLogLevel := slog.LevelDebug
+	if logDispatcherErrors {
+		dispatcherLogLevel = slog.LevelError
+	}
 	dispatcher := ext.NewDispatcher(&ext.DispatcherOpts{
-		Error: func(_ *gotgbot.Bot, _ *ext.Context, err error) ext.DispatcherAction {
-			if logDispatcherErrors {
-				log.Printf("an error occurred while handling update: %v", err)
-			}
+		Error: func(_ *gotgbot.Bot, ctx *ext.Context, err error) ext.DispatcherAction {
+			core.GetUpdateLogger(ctx).Log(
+				context.Background(),
+				dispatcherLogLevel,
+				"an error occurred while handling update",
+				"error", err,
+			)
 			return ext.DispatcherActionNoop
 		},
-		Panic: func(_ *gotgbot.Bot, _ *ext.Context, r any) {
-			if logDispatcherErrors {
-				log.Printf("panic occurred while handling update: %v\n", r)
-				log.Printf("stack trace:\n%s\n", debug.Stack())
-			}
+		Panic: func(_ *gotgbot.Bot, ctx *ext.Context, r any) {
+			core.GetUpdateLogger(ctx).Log(
+				context.Background(),
+				dispatcherLogLevel,
+				"panic occurred while handling update",
+				"panic", r,
+				"stack", string(debug.Stack()),
+			)
 		},
 		MaxRoutines: concurrentUpdates,
 	})
 	updater := ext.NewUpdater(dispatcher, nil)
 	registerHandlers(dispatcher)
-	err = updater.StartPolling(b, &ext.PollingOpts{
-		DropPendingUpdates: true,
-		GetUpdatesOpts: &gotgbot.GetUpdatesOpts{
-			Timeout: 9,
-			RequestOpts: &gotgbot.RequestOpts{
-				Timeout: time.Second * 10,
-			},
-			AllowedUpdates: allowedUpdates,
-		},
-	})
+	err = startUpdates(b, updater)
 	if err != nil {
-		log.Fatalf("failed to start polling: %v", err)
+		log.Fatalf("failed to start receiving updates: %v", err)
 	}
+	currentUpdater.Store(updater)
+	metrics.AddReadinessCheck("telegram", func(ctx context.Context) error {
+		_, err := b.GetMeWithContext(ctx, nil)
+		return err
+	})
+	metrics.SetReady(true)
 	log.Printf("bot started on: %s\n", b.User.Username)
+
+	botHandlers.RestoreJobs(b)
+}
+
+// Stop stops receiving updates and waits for the
+// running downloads until ctx is done, then
+// interrupts them and tells their users.
+// it reports whether every download stopped
+func Stop(ctx context.Context) bool {
+	metrics.SetReady(false)
+	if updater := currentUpdater.Load(); updater != nil {
+		// handlers being run are waited for
+		err := updater.Stop()
+		if err != nil {
+			log.Printf("failed to stop updater: %v", err)
+		}
+	}
+	return core.DrainJobs(ctx)
 }
 
 func registerHandlers(dispatcher *ext.Dispatcher) {
@@ -103,6 +137,10 @@ func registerHandlers(dispatcher *ext.Dispatcher) {
 		"captions",
 		botHandlers.CaptionsHandler,
 	))
+	dispatcher.AddHandler(handlers.NewCommand(
+		"transcode",
+		botHandlers.TranscodeHandler,
+	))
 	dispatcher.AddHandler(handlers.NewCommand(
 		"nsfw",
 		botHandlers.NSFWHandler,
@@ -111,6 +149,38 @@ func registerHandlers(dispatcher *ext.Dispatcher) {
 		"limit",
 		botHandlers.MediaGroupLimitHandler,
 	))
+	dispatcher.AddHandler(handlers.NewCommand(
+		"ratelimit",
+		botHandlers.RateLimitHandler,
+	))
+	dispatcher.AddHandler(handlers.NewCommand(
+		"dailylimit",
+		botHandlers.DailyLimitHandler,
+	))
+	dispatcher.AddHandler(handlers.NewCommand(
+		"formats",
+		botHandlers.FormatsHandler,
+	))
+	dispatcher.AddHandler(handlers.NewCallback(
+		callbackquery.Prefix("format:"),
+		botHandlers.FormatSelectHandler,
+	))
+	dispatcher.AddHandler(handlers.NewCommand(
+		"cancel",
+		botHandlers.CancelHandler,
+	))
+	dispatcher.AddHandler(handlers.NewCallback(
+		callbackquery.Prefix("cancel:"),
+		botHandlers.CancelButtonHandler,
+	))
+	dispatcher.AddHandler(handlers.NewCommand(
+		"reload",
+		botHandlers.ReloadHandler,
+	))
+	dispatcher.AddHandler(handlers.NewCommand(
+		"cookies",
+		botHandlers.CookiesHandler,
+	))
 	dispatcher.AddHandler(handlers.NewCallback(
 		callbackquery.Equal("stats"),
 		botHandlers.StatsHandler,
@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultWebhookListenAddr = "0.0.0.0:8080"
+	defaultWebhookPath       = "webhook"
+)
+
+// startUpdates receives updates with a webhook if
+// WEBHOOK_URL env is set, otherwise with long polling
+func startUpdates(b *gotgbot.Bot, updater *ext.Updater) error {
+	webhookURL := os.Getenv("WEBHOOK_URL")
+
+	// with a webhook, updates sent during
+	// a restart are kept by default
+	dropPendingUpdates := webhookURL == ""
+	if value := os.Getenv("DROP_PENDING_UPDATES"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Printf("failed to parse DROP_PENDING_UPDATES env, using %t", dropPendingUpdates)
+		} else {
+			dropPendingUpdates = parsed
+		}
+	}
+
+	if webhookURL == "" {
+		return updater.StartPolling(b, &ext.PollingOpts{
+			DropPendingUpdates: dropPendingUpdates,
+			GetUpdatesOpts: &gotgbot.GetUpdatesOpts{
+				Timeout: 9,
+				RequestOpts: &gotgbot.RequestOpts{
+					Timeout: time.Second * 10,
+				},
+				AllowedUpdates: allowedUpdates,
+			},
+		})
+	}
+	return startWebhook(b, updater, webhookURL, dropPendingUpdates)
+}
+
+// startWebhook serves the webhook over tls if a certificate
+// is provided, otherwise over plain http for a reverse proxy.
+// tasks, jobs and rate limits are kept in memory, so only
+// one replica can receive the updates
+func startWebhook(
+	b *gotgbot.Bot,
+	updater *ext.Updater,
+	webhookURL string,
+	dropPendingUpdates bool,
+) error {
+	secretToken := os.Getenv("WEBHOOK_SECRET")
+	if secretToken == "" {
+		return errors.New("WEBHOOK_SECRET is required in webhook mode")
+	}
+	listenAddr := os.Getenv("WEBHOOK_LISTEN_ADDR")
+	if listenAddr == "" {
+		listenAddr = defaultWebhookListenAddr
+	}
+	urlPath := strings.Trim(os.Getenv("WEBHOOK_PATH"), "/")
+	if urlPath == "" {
+		urlPath = defaultWebhookPath
+	}
+	certFile := os.Getenv("WEBHOOK_CERT_FILE")
+	keyFile := os.Getenv("WEBHOOK_KEY_FILE")
+	if (certFile == "") != (keyFile == "") {
+		return errors.New("WEBHOOK_CERT_FILE and WEBHOOK_KEY_FILE must be set together")
+	}
+
+	err := updater.StartWebhook(b, urlPath, ext.WebhookOpts{
+		ListenAddr:  listenAddr,
+		CertFile:    certFile,
+		KeyFile:     keyFile,
+		SecretToken: secretToken,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start webhook server: %w", err)
+	}
+	err = updater.SetAllBotWebhooks(strings.TrimSuffix(webhookURL, "/"), &gotgbot.SetWebhookOpts{
+		AllowedUpdates:     allowedUpdates,
+		DropPendingUpdates: dropPendingUpdates,
+		SecretToken:        secretToken,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set webhook: %w", err)
+	}
+	log.Printf("receiving updates with webhook on %s/%s", listenAddr, urlPath)
+	return nil
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"govd/bot/core"
+	"govd/enums"
+	extractors "govd/ext"
+	"govd/models"
+	"govd/util"
+
+	"github.com/bytedance/sonic"
+	"github.com/pkg/errors"
+)
+
+func ExtractCommand(args []string) error {
+	fs := flag.NewFlagSet("extract", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: govd extract <url>")
+	}
+	contentURL, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	_, response, err := extractors.ExtractURL(context.Background(), contentURL)
+	if err != nil {
+		return err
+	}
+	data, err := sonic.ConfigStd.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func DownloadCommand(args []string) error {
+	fs := flag.NewFlagSet("download", flag.ContinueOnError)
+	formatID := fs.String("f", "", "format id to download (default format if empty)")
+	outputDir := fs.String("o", ".", "output directory")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: govd download <url> [-f format] [-o dir]")
+		fs.PrintDefaults()
+	}
+	contentURL, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if !util.CheckFFmpeg() {
+		return errors.New("ffmpeg executable not found. please install it or add it to your PATH")
+	}
+
+	config := util.DefaultConfig()
+	config.DownloadDir = *outputDir
+	err = util.EnsureDownloadDir(config.DownloadDir)
+	if err != nil {
+		return err
+	}
+
+	dlCtx, response, err := extractors.ExtractURL(context.Background(), contentURL)
+	if err != nil {
+		return err
+	}
+	switch dlCtx.Extractor.Type {
+	case enums.ExtractorTypeSingle:
+		return downloadMediaList(response.MediaList, *formatID, config)
+	case enums.ExtractorTypePlaylist:
+		return downloadPlaylist(response.URLList, *formatID, config)
+	default:
+		return util.ErrUnsupportedExtractorType
+	}
+}
+
+func ListExtractorsCommand(args []string) error {
+	fs := flag.NewFlagSet("list-extractors", flag.ContinueOnError)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CODENAME\tNAME\tTYPE\tCATEGORY\tHOSTS")
+	for _, extractor := range extractors.List {
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%s\t%v\n",
+			extractor.CodeName,
+			extractor.Name,
+			extractor.Type,
+			extractor.Category,
+			extractor.Host,
+		)
+	}
+	return w.Flush()
+}
+
+func downloadPlaylist(
+	urlList []string,
+	formatID string,
+	config *models.DownloadConfig,
+) error {
+	if len(urlList) == 0 {
+		return util.ErrEmptyPlaylist
+	}
+	var failed int
+	for _, itemURL := range urlList {
+		dlCtx, response, err := extractors.ExtractURL(context.Background(), itemURL)
+		if err == nil && dlCtx.Extractor.Type != enums.ExtractorTypeSingle {
+			// nested playlists are not supported
+			err = util.ErrUnsupportedExtractorType
+		}
+		if err == nil {
+			err = downloadMediaList(response.MediaList, formatID, config)
+		}
+		if err != nil {
+			log.Printf("failed to download playlist item %s: %v", itemURL, err)
+			failed++
+		}
+	}
+	if failed == len(urlList) {
+		return util.ErrPlaylistFailed
+	}
+	return nil
+}
+
+func downloadMediaList(
+	mediaList []*models.Media,
+	formatID string,
+	config *models.DownloadConfig,
+) error {
+	if len(mediaList) == 0 {
+		return util.ErrUnavailable
+	}
+	for _, media := range mediaList {
+		format := core.GetMediaFormat(media, formatID)
+		if format == nil {
+			return fmt.Errorf("format %q not found", formatID)
+		}
+		if len(format.URL) == 0 {
+			return fmt.Errorf("media format %s has no URL", format.FormatID)
+		}
+		core.SetMediaFormat(media, format)
+	}
+
+	ctx := context.Background()
+	medias, err := core.DownloadMedias(
+		ctx,
+		mediaList,
+		config,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to download media: %w", err)
+	}
+	for _, media := range medias {
+		// thumbnails are only needed for telegram
+		if media.ThumbnailFilePath != "" {
+			os.Remove(media.ThumbnailFilePath)
+		}
+	}
+	err = core.RunPlugins(ctx, medias)
+	if err != nil {
+		for _, media := range medias {
+			os.Remove(media.FilePath)
+		}
+		return err
+	}
+	for _, media := range medias {
+		fmt.Println(media.FilePath)
+	}
+	return nil
+}
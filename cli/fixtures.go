@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	extractors "govd/ext"
+	"govd/util"
+)
+
+// RecordCommand runs the extractor while recording its
+// requests, and stores the response as a golden file
+func RecordCommand(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: govd record <url>")
+	}
+	contentURL, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	util.SetFixtureMode(util.FixtureModeRecord)
+	dlCtx, response, err := extractors.ExtractURL(context.Background(), contentURL)
+	if err != nil {
+		return err
+	}
+	goldenPath := extractors.GoldenPath(dlCtx.Extractor.CodeName, dlCtx.MatchedContentID)
+	err = extractors.WriteGoldenCase(goldenPath, &extractors.GoldenCase{
+		URL:      contentURL,
+		Response: response,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("recorded %s\n", goldenPath)
+	return nil
+}
+
+// ReplayCommand runs every extractor against its recorded
+// fixtures and compares the responses with the golden files
+func ReplayCommand(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	update := fs.Bool("update", false, "overwrite golden files with the new responses")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: govd replay [-update] [extractor...]")
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	filter := fs.Args()
+
+	util.SetFixtureMode(util.FixtureModeReplay)
+	var passed, failed, skipped int
+	for _, extractor := range extractors.List {
+		if len(filter) > 0 && !slices.Contains(filter, extractor.CodeName) {
+			continue
+		}
+		goldenPaths := extractors.GoldenPaths(extractor.CodeName)
+		if len(goldenPaths) == 0 {
+			skipped++
+			continue
+		}
+		for _, goldenPath := range goldenPaths {
+			name := extractor.CodeName + "/" + strings.TrimSuffix(filepath.Base(goldenPath), ".json")
+			diff, err := extractors.ReplayGoldenCase(goldenPath, *update)
+			switch {
+			case err != nil:
+				fmt.Printf("FAIL %s: %v\n", name, err)
+				failed++
+			case diff != "":
+				fmt.Printf("FAIL %s: response changed\n%s", name, diff)
+				failed++
+			default:
+				fmt.Printf("ok   %s\n", name)
+				passed++
+			}
+		}
+	}
+	fmt.Printf(
+		"\n%d passed, %d failed, %d extractors without fixtures\n",
+		passed, failed, skipped,
+	)
+	if failed > 0 {
+		return fmt.Errorf("%d golden files do not match", failed)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+type command struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         func(args []string) error
+}
+
+var commands = []*command{
+	{
+		Name:        "extract",
+		Usage:       "extract <url>",
+		Description: "run the extractor and print the response as json",
+		Run:         ExtractCommand,
+	},
+	{
+		Name:        "download",
+		Usage:       "download <url> [-f format] [-o dir]",
+		Description: "download the media and write the files to disk",
+		Run:         DownloadCommand,
+	},
+	{
+		Name:        "list-extractors",
+		Usage:       "list-extractors",
+		Description: "list the available extractors",
+		Run:         ListExtractorsCommand,
+	},
+	{
+		Name:        "record",
+		Usage:       "record <url>",
+		Description: "record the extractor requests as fixtures and its response as golden file",
+		Run:         RecordCommand,
+	},
+	{
+		Name:        "replay",
+		Usage:       "replay [-update] [extractor...]",
+		Description: "run extractors against their fixtures and diff the golden files",
+		Run:         ReplayCommand,
+	},
+}
+
+// IsCommand reports whether the given name
+// matches one of the cli subcommands
+func IsCommand(name string) bool {
+	return getCommand(name) != nil
+}
+
+// Run executes the subcommand in args[0]
+// and returns the process exit code
+func Run(args []string) int {
+	if len(args) == 0 {
+		printUsage()
+		return 2
+	}
+	cmd := getCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", args[0])
+		printUsage()
+		return 2
+	}
+	err := cmd.Run(args[1:])
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.Name, err)
+		return 1
+	}
+	return 0
+}
+
+func getCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage: govd <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-38s %s\n", cmd.Usage, cmd.Description)
+	}
+	fmt.Fprintln(os.Stderr, "\nwithout a command, govd starts the telegram bot")
+}
+
+// parseFlags parses flags placed both before
+// and after the positional url argument
+func parseFlags(fs *flag.FlagSet, args []string) (string, error) {
+	err := fs.Parse(args)
+	if err != nil {
+		return "", err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return "", errors.New("missing url argument")
+	}
+	contentURL := fs.Arg(0)
+	err = fs.Parse(fs.Args()[1:])
+	if err != nil {
+		return "", err
+	}
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	return contentURL, nil
+}
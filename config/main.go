@@ -3,41 +3,129 @@ package config
 import (
 	"fmt"
 	"maps"
+	"net/url"
 	"os"
+	"reflect"
+	"slices"
+	"sync"
 
 	"govd/models"
 
+	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
 )
 
-var extractorConfigs map[string]*models.ExtractorConfig
+const ConfigPath = "ext-cfg.yaml"
+
+var (
+	extractorConfigs map[string]*models.ExtractorConfig
+	configsMu        sync.RWMutex
+)
 
 func LoadExtractorConfigs() error {
-	extractorConfigs = make(map[string]*models.ExtractorConfig)
-	configPath := "ext-cfg.yaml"
+	configs, err := readExtractorConfigs()
+	if err != nil {
+		return err
+	}
+	configsMu.Lock()
+	defer configsMu.Unlock()
+	extractorConfigs = configs
+	return nil
+}
 
-	_, err := os.Stat(configPath)
+// ReloadExtractorConfigs reads the config file again and
+// replaces the current configs if it's valid. it returns
+// the names of the extractors whose config changed
+func ReloadExtractorConfigs() ([]string, error) {
+	configs, err := readExtractorConfigs()
+	if err != nil {
+		return nil, err
+	}
+	configsMu.Lock()
+	defer configsMu.Unlock()
+
+	var changed []string
+	for name, cfg := range configs {
+		if !reflect.DeepEqual(cfg, extractorConfigs[name]) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range extractorConfigs {
+		if _, exists := configs[name]; !exists {
+			changed = append(changed, name)
+		}
+	}
+	extractorConfigs = configs
+	slices.Sort(changed)
+	return changed, nil
+}
+
+func GetExtractorConfig(codeName string) *models.ExtractorConfig {
+	configsMu.RLock()
+	defer configsMu.RUnlock()
+	if config, exists := extractorConfigs[codeName]; exists {
+		return config
+	}
+	return nil
+}
+
+func readExtractorConfigs() (map[string]*models.ExtractorConfig, error) {
+	configs := make(map[string]*models.ExtractorConfig)
+
+	_, err := os.Stat(ConfigPath)
 	if os.IsNotExist(err) {
-		return nil
+		return configs, nil
 	}
-	data, err := os.ReadFile(configPath)
+	data, err := os.ReadFile(ConfigPath)
 	if err != nil {
-		return fmt.Errorf("failed reading config file: %w", err)
+		return nil, fmt.Errorf("failed reading config file: %w", err)
 	}
 
 	var rawConfig map[string]*models.ExtractorConfig
 
 	if err := yaml.Unmarshal(data, &rawConfig); err != nil {
-		return fmt.Errorf("failed parsing config file: %w", err)
+		return nil, fmt.Errorf("failed parsing config file: %w", err)
+	}
+	for name, cfg := range rawConfig {
+		if cfg == nil {
+			// an extractor key without options
+			delete(rawConfig, name)
+			continue
+		}
+		if err := validateExtractorConfig(cfg); err != nil {
+			return nil, fmt.Errorf("invalid config for %s: %w", name, err)
+		}
 	}
-	maps.Copy(extractorConfigs, rawConfig)
+	maps.Copy(configs, rawConfig)
 
-	return nil
+	return configs, nil
 }
 
-func GetExtractorConfig(codeName string) *models.ExtractorConfig {
-	if config, exists := extractorConfigs[codeName]; exists {
-		return config
+func validateExtractorConfig(cfg *models.ExtractorConfig) error {
+	proxies := []string{cfg.HTTPProxy, cfg.HTTPSProxy, cfg.EdgeProxyURL}
+	proxies = append(proxies, cfg.Proxies...)
+	for _, rawURL := range proxies {
+		if rawURL == "" {
+			continue
+		}
+		proxyURL, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("invalid url %q: %w", rawURL, err)
+		}
+		if proxyURL.Host == "" {
+			return fmt.Errorf("invalid url %q: missing host", rawURL)
+		}
+	}
+	switch cfg.ProxyStrategy {
+	case "", "round_robin", "random", "sticky":
+	default:
+		return fmt.Errorf("unknown proxy strategy %q", cfg.ProxyStrategy)
+	}
+	if cfg.MaxConcurrency < 0 {
+		return errors.New("max_concurrency must not be negative")
+	}
+	if cfg.ProxyCooldown < 0 || cfg.ProxyHealthInterval < 0 {
+		return errors.New("proxy intervals must not be negative")
 	}
 	return nil
 }
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"govd/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// SavePendingJob stores the job, unless it's already
+// stored: restored jobs keep their creation time
+func SavePendingJob(
+	job *models.PendingJob,
+) error {
+	err := DB.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(job).
+		Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetPendingJobs returns the jobs of the instance,
+// including the ones stored before instances existed
+func GetPendingJobs(
+	instance string,
+) ([]*models.PendingJob, error) {
+	var jobs []*models.PendingJob
+	err := DB.
+		Where("instance = ? OR instance IS NULL", instance).
+		Order("created_at").
+		Find(&jobs).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func DeletePendingJob(
+	id string,
+) error {
+	err := DB.
+		Where(&models.PendingJob{
+			ID: id,
+		}).
+		Delete(&models.PendingJob{}).
+		Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
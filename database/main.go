@@ -2,6 +2,7 @@ package database
 
 import (
 	"govd/models"
+	"govd/util/metrics"
 
 	"fmt"
 	"log"
@@ -9,6 +10,8 @@ import (
 	"time"
 
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
@@ -32,32 +35,44 @@ func Start() {
 	if err != nil {
 		log.Fatalf("failed to migrate database: %v", err)
 	}
+	metrics.AddHealthCheck("database", sqlDB.PingContext)
 }
 
-func connect() *gorm.DB {
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
+// Close closes the database connection pool
+func Close() {
+	if DB == nil {
+		return
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return
+	}
+	err = sqlDB.Close()
+	if err != nil {
+		log.Printf("failed to close database: %v", err)
+	}
+}
 
-	connectionString := fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True",
-		user, password, host, port, dbname,
-	)
+func connect() *gorm.DB {
+	dialector, err := getDialector()
+	if err != nil {
+		log.Fatalf("failed to configure database: %v", err)
+	}
+	location, err := getTimezone()
+	if err != nil {
+		log.Fatalf("failed to load database timezone: %v", err)
+	}
 
 	var conn *gorm.DB
-	var err error
 
 	maxRetries := 10
 	retryCount := 0
 
 	for retryCount < maxRetries {
-		conn, err = gorm.Open(mysql.Open(connectionString), &gorm.Config{
+		conn, err = gorm.Open(dialector, &gorm.Config{
 			Logger: logger.Default.LogMode(logger.Silent),
 			NowFunc: func() time.Time {
-				utc, _ := time.LoadLocation("Europe/Rome")
-				return time.Now().In(utc)
+				return time.Now().In(location)
 			},
 		})
 		if err == nil {
@@ -75,12 +90,65 @@ func connect() *gorm.DB {
 	return conn
 }
 
+func getDialector() (gorm.Dialector, error) {
+	driver := os.Getenv("DB_DRIVER")
+	if driver == "" {
+		driver = "mysql"
+	}
+
+	host := os.Getenv("DB_HOST")
+	port := os.Getenv("DB_PORT")
+	user := os.Getenv("DB_USER")
+	password := os.Getenv("DB_PASSWORD")
+	dbname := os.Getenv("DB_NAME")
+
+	switch driver {
+	case "mysql":
+		connectionString := fmt.Sprintf(
+			"%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True",
+			user, password, host, port, dbname,
+		)
+		return mysql.Open(connectionString), nil
+	case "postgres":
+		sslMode := os.Getenv("DB_SSLMODE")
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		connectionString := fmt.Sprintf(
+			"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+			host, port, user, password, dbname, sslMode,
+		)
+		return postgres.Open(connectionString), nil
+	case "sqlite":
+		path := os.Getenv("DB_PATH")
+		if path == "" {
+			path = "govd.db"
+		}
+		// WAL and busy timeout allow concurrent
+		// readers while a write is in progress
+		connectionString := path + "?_journal_mode=WAL&_busy_timeout=5000"
+		return sqlite.Open(connectionString), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+}
+
+func getTimezone() (*time.Location, error) {
+	timezone := os.Getenv("DB_TIMEZONE")
+	if timezone == "" {
+		timezone = "Europe/Rome"
+	}
+	return time.LoadLocation(timezone)
+}
+
 func migrateDatabase() error {
 	err := DB.AutoMigrate(
 		&models.Media{},
 		&models.MediaFormat{},
 		&models.GroupSettings{},
 		&models.User{},
+		&models.Usage{},
+		&models.PendingJob{},
 	)
 	if err != nil {
 		return err
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"govd/models"
+	"govd/util/metrics"
 
 	"gorm.io/gorm"
 )
@@ -27,9 +28,51 @@ func GetDefaultMedias(
 		return nil, fmt.Errorf("failed to get stored media list: %w", err)
 	}
 
+	mediaList = filterStoredMedias(mediaList)
+	if len(mediaList) > 0 {
+		metrics.CacheLookups.Inc("hit")
+	} else {
+		metrics.CacheLookups.Inc("miss")
+	}
 	return mediaList, nil
 }
 
+func GetFormatMedias(
+	extractorCodeName string,
+	contentID string,
+	formatID string,
+) ([]*models.Media, error) {
+	var mediaList []*models.Media
+
+	err := DB.
+		Where(&models.Media{
+			ExtractorCodeName: extractorCodeName,
+			ContentID:         contentID,
+		}).
+		Preload("Format", "format_id = ?", formatID).
+		Find(&mediaList).
+		Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored media list: %w", err)
+	}
+
+	return filterStoredMedias(mediaList), nil
+}
+
+// filterStoredMedias drops medias whose preloaded
+// format didn't match, since every stored format
+// has its own media row
+func filterStoredMedias(mediaList []*models.Media) []*models.Media {
+	filtered := make([]*models.Media, 0, len(mediaList))
+	for _, media := range mediaList {
+		if media.Format != nil {
+			filtered = append(filtered, media)
+		}
+	}
+	return filtered
+}
+
 func StoreMedia(
 	extractorCodeName string,
 	contentID string,
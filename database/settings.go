@@ -35,3 +35,21 @@ func UpdateGroupSettings(
 	}
 	return nil
 }
+
+func UpdateGroupSetting(
+	chatID int64,
+	column string,
+	value any,
+) error {
+	err := DB.
+		Model(&models.GroupSettings{}).
+		Where(&models.GroupSettings{
+			ChatID: chatID,
+		}).
+		Update(column, value).
+		Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,6 +1,10 @@
 package database
 
-import "govd/models"
+import (
+	"time"
+
+	"govd/models"
+)
 
 func GetMediaCount() (int64, error) {
 	var count int64
@@ -39,10 +43,17 @@ func GetGroupsCount() (int64, error) {
 }
 
 func GetDailyUserCount() (int64, error) {
+	// computing the day start here keeps the
+	// query portable across database drivers
+	now := DB.NowFunc()
+	startOfDay := time.Date(
+		now.Year(), now.Month(), now.Day(),
+		0, 0, 0, 0, now.Location(),
+	)
 	var count int64
 	err := DB.
 		Model(&models.User{}).
-		Where("DATE(last_used) = DATE(NOW())").
+		Where("last_used >= ?", startOfDay).
 		Count(&count).
 		Error
 	if err != nil {
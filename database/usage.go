@@ -0,0 +1,74 @@
+package database
+
+import (
+	"time"
+
+	"govd/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const usageDayLayout = "2006-01-02"
+
+func GetDailyUsage(
+	id int64,
+) (*models.Usage, error) {
+	var usage models.Usage
+	err := DB.
+		Where(&models.Usage{
+			ID:  id,
+			Day: getUsageDay(),
+		}).
+		Limit(1).
+		Find(&usage).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+func AddDailyUsage(
+	id int64,
+	downloads int,
+	bytes int64,
+) error {
+	err := DB.
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{
+				{Name: "id"},
+				{Name: "day"},
+			},
+			DoUpdates: clause.Assignments(map[string]any{
+				"downloads": gorm.Expr("downloads + ?", downloads),
+				"bytes":     gorm.Expr("bytes + ?", bytes),
+			}),
+		}).
+		Create(&models.Usage{
+			ID:        id,
+			Day:       getUsageDay(),
+			Downloads: downloads,
+			Bytes:     bytes,
+		}).
+		Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetUsageResetTime returns the time left
+// until daily usages are reset
+func GetUsageResetTime() time.Duration {
+	now := DB.NowFunc()
+	tomorrow := time.Date(
+		now.Year(), now.Month(), now.Day()+1,
+		0, 0, 0, 0, now.Location(),
+	)
+	return tomorrow.Sub(now)
+}
+
+func getUsageDay() string {
+	return DB.NowFunc().Format(usageDayLayout)
+}
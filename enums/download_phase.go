@@ -0,0 +1,12 @@
+package enums
+
+type DownloadPhase string
+
+const (
+	DownloadPhaseExtracting  DownloadPhase = "extracting"
+	DownloadPhaseDownloading DownloadPhase = "downloading"
+	DownloadPhaseMerging     DownloadPhase = "merging"
+	DownloadPhaseTranscoding DownloadPhase = "transcoding"
+	DownloadPhaseCompressing DownloadPhase = "compressing"
+	DownloadPhaseUploading   DownloadPhase = "uploading"
+)
@@ -3,5 +3,6 @@ package enums
 type ExtractorType string
 
 const (
-	ExtractorTypeSingle ExtractorType = "single"
+	ExtractorTypeSingle   ExtractorType = "single"
+	ExtractorTypePlaylist ExtractorType = "playlist"
 )
@@ -0,0 +1,138 @@
+package ext
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"govd/models"
+	"govd/util"
+
+	"github.com/bytedance/sonic"
+	"github.com/pkg/errors"
+)
+
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// GoldenCase is the expected extractor response for a url
+type GoldenCase struct {
+	URL      string                    `json:"url"`
+	Response *models.ExtractorResponse `json:"response"`
+}
+
+// GoldenPath returns the golden file of the content,
+// in the fixtures directory of the extractor
+func GoldenPath(codeName string, contentID string) string {
+	name := unsafeNameChars.ReplaceAllString(contentID, "_")
+	if name == "" {
+		name = "default"
+	}
+	return filepath.Join(util.GetFixturesDir(), codeName, "golden", name+".json")
+}
+
+// GoldenPaths returns the golden files of the extractor
+func GoldenPaths(codeName string) []string {
+	goldenPaths, _ := filepath.Glob(
+		filepath.Join(util.GetFixturesDir(), codeName, "golden", "*.json"),
+	)
+	return goldenPaths
+}
+
+func WriteGoldenCase(goldenPath string, golden *GoldenCase) error {
+	data, err := sonic.ConfigStd.MarshalIndent(golden, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode golden file: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(goldenPath), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create golden directory: %w", err)
+	}
+	err = os.WriteFile(goldenPath, append(data, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write golden file: %w", err)
+	}
+	return nil
+}
+
+// ReplayGoldenCase extracts the url of the golden file, with
+// the fixture mode set to replay, and returns the diff between
+// the golden response and the new one. with update, the golden
+// file is overwritten instead
+func ReplayGoldenCase(goldenPath string, update bool) (string, error) {
+	data, err := os.ReadFile(goldenPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read golden file: %w", err)
+	}
+	var golden GoldenCase
+	err = sonic.ConfigFastest.Unmarshal(data, &golden)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse golden file: %w", err)
+	}
+	if golden.URL == "" {
+		return "", errors.New("golden file has no url")
+	}
+
+	_, response, err := ExtractURL(context.Background(), golden.URL)
+	if err != nil {
+		return "", err
+	}
+	if update {
+		golden.Response = response
+		return "", WriteGoldenCase(goldenPath, &golden)
+	}
+
+	expected, err := sonic.ConfigStd.MarshalIndent(golden.Response, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode golden response: %w", err)
+	}
+	actual, err := sonic.ConfigStd.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode response: %w", err)
+	}
+	return diffLines(string(expected), string(actual)), nil
+}
+
+// diffLines returns the lines removed (-) and added (+)
+// from expected to actual, or an empty string if equal
+func diffLines(expected string, actual string) string {
+	if expected == actual {
+		return ""
+	}
+	a := strings.Split(expected, "\n")
+	b := strings.Split(actual, "\n")
+
+	// longest common subsequence of lines
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var builder strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			fmt.Fprintf(&builder, "  + %s\n", b[j])
+			j++
+		default:
+			fmt.Fprintf(&builder, "  - %s\n", a[i])
+			i++
+		}
+	}
+	return builder.String()
+}
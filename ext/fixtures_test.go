@@ -0,0 +1,42 @@
+package ext
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"govd/util"
+)
+
+// TestReplayFixtures replays the fixtures of every extractor
+// in testdata/fixtures and compares the responses with their
+// golden files. to record or update them, from the repository
+// root: HTTP_FIXTURES_DIR=ext/testdata/fixtures govd record <url>
+func TestReplayFixtures(t *testing.T) {
+	t.Setenv("HTTP_FIXTURES_DIR", filepath.Join("testdata", "fixtures"))
+	util.SetFixtureMode(util.FixtureModeReplay)
+	t.Cleanup(func() {
+		util.SetFixtureMode(util.FixtureModeOff)
+	})
+
+	var replayed int
+	for _, extractor := range List {
+		for _, goldenPath := range GoldenPaths(extractor.CodeName) {
+			replayed++
+			name := extractor.CodeName + "/" +
+				strings.TrimSuffix(filepath.Base(goldenPath), ".json")
+			t.Run(name, func(t *testing.T) {
+				diff, err := ReplayGoldenCase(goldenPath, false)
+				if err != nil {
+					t.Fatalf("replay failed: %v", err)
+				}
+				if diff != "" {
+					t.Errorf("response changed\n%s", diff)
+				}
+			})
+		}
+	}
+	if replayed == 0 {
+		t.Fatal("no golden files found")
+	}
+}
@@ -24,27 +24,38 @@ var Extractor = &models.Extractor{
 	IsRedirect: false,
 
 	Run: func(ctx *models.DownloadContext) (*models.ExtractorResponse, error) {
+		logger := util.GetLogger(ctx.Context)
 		// method 1: get media from GQL web API
-		mediaList, err := GetGQLMediaList(ctx)
-		if err == nil && len(mediaList) > 0 {
+		mediaList, gqlErr := GetGQLMediaList(ctx)
+		if gqlErr == nil && len(mediaList) > 0 {
 			return &models.ExtractorResponse{
 				MediaList: mediaList,
 			}, nil
 		}
+		logger.Debug("gql method failed", "error", gqlErr)
 		// method 2: get media from embed page
-		mediaList, err = GetEmbedMediaList(ctx)
-		if err == nil && len(mediaList) > 0 {
+		mediaList, embedErr := GetEmbedMediaList(ctx)
+		if embedErr == nil && len(mediaList) > 0 {
 			return &models.ExtractorResponse{
 				MediaList: mediaList,
 			}, nil
 		}
+		logger.Debug("embed method failed", "error", embedErr)
 		// method 3: get media from 3rd party service (unlikely)
-		mediaList, err = GetIGramMediaList(ctx)
-		if err == nil && len(mediaList) > 0 {
+		mediaList, igramErr := GetIGramMediaList(ctx)
+		if igramErr == nil && len(mediaList) > 0 {
 			return &models.ExtractorResponse{
 				MediaList: mediaList,
 			}, nil
 		}
+		logger.Debug("igram method failed", "error", igramErr)
+		// report why the official APIs failed, if known
+		var extractorError *util.ExtractorError
+		for _, err := range []error{gqlErr, embedErr} {
+			if errors.As(err, &extractorError) {
+				return nil, fmt.Errorf("failed to extract media: %w", err)
+			}
+		}
 		return nil, errors.New("failed to extract media: all methods failed")
 	},
 }
@@ -106,7 +117,8 @@ func GetEmbedMediaList(
 ) ([]*models.Media, error) {
 	session := util.GetHTTPClient(ctx.Extractor.CodeName)
 	embedURL := fmt.Sprintf("https://www.instagram.com/p/%s/embed/captioned", ctx.MatchedContentID)
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx.Context,
 		http.MethodGet,
 		embedURL,
 		nil,
@@ -123,7 +135,10 @@ func GetEmbedMediaList(
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get embed page: %s", resp.Status)
+		return nil, util.NewExtractorError(
+			util.ErrorFromStatus(resp.StatusCode),
+			fmt.Errorf("failed to get embed page: %s", resp.Status),
+		)
 	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -198,7 +213,7 @@ func GetFromIGram(
 	if err != nil {
 		return nil, fmt.Errorf("failed to build signed payload: %w", err)
 	}
-	req, err := http.NewRequest(http.MethodPost, apiURL, payload)
+	req, err := http.NewRequestWithContext(ctx.Context, http.MethodPost, apiURL, payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -258,7 +258,8 @@ func GetGQLData(
 	formData.Set("variables", string(variablesJSON))
 	formData.Set("server_timestamps", "true")
 	formData.Set("doc_id", "8845758582119845") // idk what this is
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx.Context,
 		http.MethodPost,
 		graphQLEndpoint,
 		strings.NewReader(formData.Encode()),
@@ -278,21 +279,38 @@ func GetGQLData(
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("invalid response code: %s", resp.Status)
+		return nil, util.NewExtractorError(
+			util.ErrorFromStatus(resp.StatusCode),
+			fmt.Errorf("invalid response code: %s", resp.Status),
+		)
 	}
 	var response GraphQLResponse
 	decoder := sonic.ConfigFastest.NewDecoder(resp.Body)
 	if err := decoder.Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			fmt.Errorf("failed to parse response: %w", err),
+		)
 	}
 	if response.Data == nil {
-		return nil, errors.New("data is nil")
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			errors.New("data is nil"),
+		)
 	}
 	if response.Status != "ok" {
-		return nil, fmt.Errorf("status is not ok: %s", response.Status)
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			fmt.Errorf("status is not ok: %s", response.Status),
+		)
 	}
 	if response.Data.ShortcodeMedia == nil {
-		return nil, errors.New("media is nil")
+		// private, deleted and age restricted posts
+		// have no media for logged out users
+		return nil, util.NewExtractorError(
+			util.ErrUnavailable,
+			errors.New("media is nil"),
+		)
 	}
 	return response.Data, nil
 }
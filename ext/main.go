@@ -25,6 +25,7 @@ var List = []*models.Extractor{
 	pinterest.ShortExtractor,
 	reddit.Extractor,
 	reddit.ShortExtractor,
+	reddit.SubredditExtractor,
 	ninegag.Extractor,
 	redgifs.Extractor,
 }
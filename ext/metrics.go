@@ -0,0 +1,47 @@
+package ext
+
+import (
+	"time"
+
+	"govd/models"
+	"govd/util"
+	"govd/util/metrics"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	for _, extractor := range List {
+		instrumentExtractor(extractor)
+	}
+}
+
+// instrumentExtractor records and logs the
+// outcome and duration of each run of the extractor
+func instrumentExtractor(extractor *models.Extractor) {
+	run := extractor.Run
+	extractor.Run = func(ctx *models.DownloadContext) (*models.ExtractorResponse, error) {
+		start := time.Now()
+		response, err := run(ctx)
+		metrics.ExtractorDuration.ObserveSince(start, extractor.CodeName)
+		outcome := runOutcome(err)
+		metrics.ExtractorRuns.Inc(extractor.CodeName, outcome)
+		util.GetLogger(ctx.Context).Debug(
+			"extractor run finished",
+			"outcome", outcome,
+			"duration", time.Since(start),
+		)
+		return response, err
+	}
+}
+
+func runOutcome(err error) string {
+	if err == nil {
+		return "success"
+	}
+	var extractorError *util.ExtractorError
+	if errors.As(err, &extractorError) {
+		return extractorError.Kind.Code
+	}
+	return "error"
+}
@@ -1,6 +1,7 @@
 package ninegag
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -45,7 +46,7 @@ func MediaListFromAPI(ctx *models.DownloadContext) ([]*models.Media, error) {
 	contentID := ctx.MatchedContentID
 	contentURL := ctx.MatchedContentURL
 
-	postData, err := GetPostData(contentID)
+	postData, err := GetPostData(ctx.Context, contentID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get post data: %w", err)
 	}
@@ -90,41 +91,56 @@ func MediaListFromAPI(ctx *models.DownloadContext) ([]*models.Media, error) {
 	return nil, nil
 }
 
-func GetPostData(postID string) (*Post, error) {
+func GetPostData(ctx context.Context, postID string) (*Post, error) {
 	url := apiEndpoint + "?id=" + postID
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("User-Agent", util.ChromeUA)
 
-	resp, err := httpSession.Do(req)
+	resp, err := util.WithFixtures("ninegag", httpSession).Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("invalid status code: %d", resp.StatusCode)
+		return nil, util.NewExtractorError(
+			util.ErrorFromStatus(resp.StatusCode),
+			fmt.Errorf("invalid status code: %d", resp.StatusCode),
+		)
 	}
 
 	var response Response
 	decoder := sonic.ConfigFastest.NewDecoder(resp.Body)
 	err = decoder.Decode(&response)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			fmt.Errorf("failed to decode response: %w", err),
+		)
 	}
 
-	if response.Meta != nil && response.Meta.Status != "Success" {
-		return nil, fmt.Errorf("API error: %s", response.Meta.Status)
+	if response.Meta != nil && response.Meta.ErrorMessage == postNotFound {
+		return nil, util.NewExtractorError(
+			util.ErrContentDeleted,
+			errors.New(postNotFound),
+		)
 	}
 
-	if response.Meta != nil && response.Meta.ErrorMessage == postNotFound {
-		return nil, util.ErrUnavailable
+	if response.Meta != nil && response.Meta.Status != "Success" {
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			fmt.Errorf("API error: %s", response.Meta.Status),
+		)
 	}
 
 	if response.Data == nil || response.Data.Post == nil {
-		return nil, errors.New("no post data found")
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			errors.New("no post data found"),
+		)
 	}
 
 	return response.Data.Post, nil
@@ -1,6 +1,7 @@
 package pinterest
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -89,7 +90,7 @@ func ExtractPinMedia(ctx *models.DownloadContext) ([]*models.Media, error) {
 	contentURL := ctx.MatchedContentURL
 	session := util.GetHTTPClient(ctx.Extractor.CodeName)
 
-	pinData, err := GetPinData(session, pinID)
+	pinData, err := GetPinData(ctx.Context, session, pinID)
 	if err != nil {
 		return nil, err
 	}
@@ -156,16 +157,20 @@ func ExtractPinMedia(ctx *models.DownloadContext) ([]*models.Media, error) {
 		return []*models.Media{media}, nil
 	}
 
-	return nil, fmt.Errorf("no media found for pin ID: %s", pinID)
+	return nil, util.NewExtractorError(
+		util.ErrUnsupportedPostType,
+		fmt.Errorf("no media found for pin ID: %s", pinID),
+	)
 }
 
 func GetPinData(
+	ctx context.Context,
 	session models.HTTPClient,
 	pinID string,
 ) (*PinData, error) {
 	params := BuildPinRequestParams(pinID)
 
-	req, err := http.NewRequest(http.MethodGet, pinResourceEndpoint, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pinResourceEndpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -186,14 +191,20 @@ func GetPinData(
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad response: %s", resp.Status)
+		return nil, util.NewExtractorError(
+			util.ErrorFromStatus(resp.StatusCode),
+			fmt.Errorf("bad response: %s", resp.Status),
+		)
 	}
 
 	var pinResponse PinResponse
 	decoder := sonic.ConfigFastest.NewDecoder(resp.Body)
 	err = decoder.Decode(&pinResponse)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			fmt.Errorf("failed to parse response: %w", err),
+		)
 	}
 
 	return &pinResponse.ResourceResponse.Data, nil
@@ -1,6 +1,7 @@
 package reddit
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -33,13 +34,17 @@ var ShortExtractor = &models.Extractor{
 
 	Run: func(ctx *models.DownloadContext) (*models.ExtractorResponse, error) {
 		client := util.GetHTTPClient(ctx.Extractor.CodeName)
-		req, err := http.NewRequest(http.MethodGet, ctx.MatchedContentURL, nil)
+		req, err := http.NewRequestWithContext(ctx.Context, http.MethodGet, ctx.MatchedContentURL, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
 
 		req.Header.Set("User-Agent", util.ChromeUA)
-		cookies, err := util.ParseCookieFile("reddit.txt")
+		jar, err := util.GetCookieJar("reddit")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cookies: %w", err)
+		}
+		cookies, err := jar.Cookies()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get cookies: %w", err)
 		}
@@ -53,6 +58,11 @@ var ShortExtractor = &models.Extractor{
 		}
 		defer res.Body.Close()
 
+		err = jar.CheckResponse(res)
+		if err != nil {
+			return nil, err
+		}
+
 		location := res.Request.URL.String()
 
 		return &models.ExtractorResponse{
@@ -80,6 +90,25 @@ var Extractor = &models.Extractor{
 	},
 }
 
+var SubredditExtractor = &models.Extractor{
+	Name:       "Reddit (Subreddit)",
+	CodeName:   "reddit_subreddit",
+	Type:       enums.ExtractorTypePlaylist,
+	Category:   enums.ExtractorCategorySocial,
+	URLPattern: regexp.MustCompile(`https?://(?P<host>(?:\w+\.)?reddit(?:media)?\.com)/(?P<slug>r/(?P<id>[^/?#&]+))/?(?:[?#]|$)`),
+	Host:       baseHost,
+
+	Run: func(ctx *models.DownloadContext) (*models.ExtractorResponse, error) {
+		urlList, err := PostURLsFromListing(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get posts: %w", err)
+		}
+		return &models.ExtractorResponse{
+			URLList: urlList,
+		}, nil
+	},
+}
+
 func MediaListFromAPI(ctx *models.DownloadContext) ([]*models.Media, error) {
 	session := util.GetHTTPClient(ctx.Extractor.CodeName)
 
@@ -89,13 +118,16 @@ func MediaListFromAPI(ctx *models.DownloadContext) ([]*models.Media, error) {
 	contentID := ctx.MatchedContentID
 	contentURL := ctx.MatchedContentURL
 
-	manifest, err := GetRedditData(session, host, slug, false)
+	manifest, err := GetRedditData(ctx.Context, session, host, slug, false)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(manifest) == 0 || len(manifest[0].Data.Children) == 0 {
-		return nil, errors.New("no data found in response")
+		return nil, util.NewExtractorError(
+			util.ErrContentDeleted,
+			errors.New("no data found in response"),
+		)
 	}
 
 	data := manifest[0].Data.Children[0].Data
@@ -226,7 +258,97 @@ func MediaListFromAPI(ctx *models.DownloadContext) ([]*models.Media, error) {
 	return nil, nil
 }
 
+func PostURLsFromListing(ctx *models.DownloadContext) ([]string, error) {
+	session := util.GetHTTPClient(ctx.Extractor.CodeName)
+
+	host := ctx.MatchedGroups["host"]
+	slug := ctx.MatchedGroups["slug"]
+
+	listing, err := GetListingData(ctx.Context, session, host, slug)
+	if err != nil {
+		return nil, err
+	}
+	if listing.Data == nil {
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			errors.New("no data found in response"),
+		)
+	}
+
+	urlList := make([]string, 0, len(listing.Data.Children))
+	for _, child := range listing.Data.Children {
+		post := child.Data
+		if post == nil || post.Permalink == "" || post.Stickied {
+			continue
+		}
+		// skip text-only posts
+		if !post.IsVideo && post.Preview == nil && len(post.MediaMetadata) == 0 {
+			continue
+		}
+		urlList = append(urlList, "https://www.reddit.com"+post.Permalink)
+	}
+	return urlList, nil
+}
+
+func GetListingData(
+	ctx context.Context,
+	session models.HTTPClient,
+	host string,
+	slug string,
+) (*ResponseItem, error) {
+	url := fmt.Sprintf("https://%s/%s/.json?limit=%d", host, slug, listingLimit)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", util.ChromeUA)
+	jar, err := util.GetCookieJar("reddit")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cookies: %w", err)
+	}
+	cookies, err := jar.Cookies()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cookies: %w", err)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	res, err := session.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer res.Body.Close()
+
+	err = jar.CheckResponse(res)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, util.NewExtractorError(
+			StatusErrorKind(res.StatusCode),
+			fmt.Errorf("failed to get reddit listing: %s", res.Status),
+		)
+	}
+
+	var response ResponseItem
+	decoder := sonic.ConfigFastest.NewDecoder(res.Body)
+	err = decoder.Decode(&response)
+	if err != nil {
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			fmt.Errorf("failed to parse response: %w", err),
+		)
+	}
+
+	return &response, nil
+}
+
 func GetRedditData(
+	ctx context.Context,
 	session models.HTTPClient,
 	host string,
 	slug string,
@@ -234,13 +356,17 @@ func GetRedditData(
 ) (Response, error) {
 	url := fmt.Sprintf("https://%s/%s/.json", host, slug)
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("User-Agent", util.ChromeUA)
-	cookies, err := util.ParseCookieFile("reddit.txt")
+	jar, err := util.GetCookieJar("reddit")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cookies: %w", err)
+	}
+	cookies, err := jar.Cookies()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cookies: %w", err)
 	}
@@ -254,9 +380,17 @@ func GetRedditData(
 	}
 	defer res.Body.Close()
 
+	err = jar.CheckResponse(res)
+	if err != nil {
+		return nil, err
+	}
+
 	if res.StatusCode != http.StatusOK {
 		if raise {
-			return nil, fmt.Errorf("failed to get reddit data: %s", res.Status)
+			return nil, util.NewExtractorError(
+				StatusErrorKind(res.StatusCode),
+				fmt.Errorf("failed to get reddit data: %s", res.Status),
+			)
 		}
 		// try with alternative domain
 		altHost := "old.reddit.com"
@@ -264,14 +398,17 @@ func GetRedditData(
 			altHost = "www.reddit.com"
 		}
 
-		return GetRedditData(session, altHost, slug, true)
+		return GetRedditData(ctx, session, altHost, slug, true)
 	}
 
 	var response Response
 	decoder := sonic.ConfigFastest.NewDecoder(res.Body)
 	err = decoder.Decode(&response)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			fmt.Errorf("failed to parse response: %w", err),
+		)
 	}
 
 	return response, nil
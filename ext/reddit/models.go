@@ -17,6 +17,8 @@ type Response []*ResponseItem
 type PostData struct {
 	ID            string                   `json:"id"`
 	Title         string                   `json:"title"`
+	Permalink     string                   `json:"permalink"`
+	Stickied      bool                     `json:"stickied"`
 	IsVideo       bool                     `json:"is_video"`
 	Thumbnail     string                   `json:"thumbnail"`
 	Media         *Media                   `json:"media"`
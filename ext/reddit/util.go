@@ -5,11 +5,13 @@ import (
 	"govd/models"
 	"govd/util"
 	"govd/util/parser"
+	"net/http"
 	"regexp"
 )
 
 const (
 	hlsURLFormat = "https://v.redd.it/%s/HLSPlaylist.m3u8"
+	listingLimit = 25
 )
 
 var videoURLPattern = regexp.MustCompile(`https?://v\.redd\.it/([^/]+)`)
@@ -37,3 +39,12 @@ func GetHLSFormats(videoURL string, thumbnail string, duration int64) ([]*models
 
 	return formats, nil
 }
+
+// StatusErrorKind maps a reddit response status to its
+// error. private and banned subreddits return 403
+func StatusErrorKind(statusCode int) *util.Error {
+	if statusCode == http.StatusForbidden {
+		return util.ErrContentPrivate
+	}
+	return util.ErrorFromStatus(statusCode)
+}
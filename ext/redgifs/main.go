@@ -1,6 +1,7 @@
 package redgifs
 
 import (
+	"context"
 	"fmt"
 	"govd/enums"
 	"govd/models"
@@ -23,6 +24,11 @@ var (
 		"origin":       "https://www.redgifs.com",
 		"content-type": "application/json",
 	}
+	// the media cdn rejects requests without them
+	downloadHeaders = map[string]string{
+		"referer":    "https://www.redgifs.com/",
+		"user-agent": util.ChromeUA,
+	}
 )
 
 var Extractor = &models.Extractor{
@@ -51,7 +57,7 @@ func MediaListFromAPI(ctx *models.DownloadContext) ([]*models.Media, error) {
 	client := util.GetHTTPClient(ctx.Extractor.CodeName)
 
 	response, err := GetVideo(
-		client, ctx.MatchedContentID)
+		ctx.Context, client, ctx.MatchedContentID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get from api: %w", err)
 	}
@@ -68,12 +74,13 @@ func MediaListFromAPI(ctx *models.DownloadContext) ([]*models.Media, error) {
 
 	if gif.Urls.Sd != "" {
 		format := &models.MediaFormat{
-			FormatID:   "sd",
-			Type:       enums.MediaTypeVideo,
-			URL:        []string{gif.Urls.Sd},
-			VideoCodec: enums.MediaCodecAVC,
-			Width:      int64(gif.Width / 2),
-			Height:     int64(gif.Height / 2),
+			FormatID:        "sd",
+			Type:            enums.MediaTypeVideo,
+			URL:             []string{gif.Urls.Sd},
+			VideoCodec:      enums.MediaCodecAVC,
+			DownloadHeaders: downloadHeaders,
+			Width:           int64(gif.Width / 2),
+			Height:          int64(gif.Height / 2),
 		}
 		if gif.HasAudio {
 			format.AudioCodec = enums.MediaCodecAAC
@@ -83,12 +90,13 @@ func MediaListFromAPI(ctx *models.DownloadContext) ([]*models.Media, error) {
 
 	if gif.Urls.Hd != "" {
 		format := &models.MediaFormat{
-			FormatID:   "hd",
-			Type:       enums.MediaTypeVideo,
-			URL:        []string{gif.Urls.Hd},
-			VideoCodec: enums.MediaCodecAVC,
-			Width:      int64(gif.Width),
-			Height:     int64(gif.Height),
+			FormatID:        "hd",
+			Type:            enums.MediaTypeVideo,
+			URL:             []string{gif.Urls.Hd},
+			VideoCodec:      enums.MediaCodecAVC,
+			DownloadHeaders: downloadHeaders,
+			Width:           int64(gif.Width),
+			Height:          int64(gif.Height),
 		}
 		if gif.HasAudio {
 			format.AudioCodec = enums.MediaCodecAAC
@@ -112,15 +120,16 @@ func MediaListFromAPI(ctx *models.DownloadContext) ([]*models.Media, error) {
 }
 
 func GetVideo(
+	ctx context.Context,
 	client models.HTTPClient,
 	videoID string,
 ) (*Response, error) {
 	url := videoEndpoint + videoID + "?views=true"
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	token, err := GetAccessToken(client)
+	token, err := GetAccessToken(ctx, client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get access token: %w", err)
 	}
@@ -136,15 +145,24 @@ func GetVideo(
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get video: %s", res.Status)
+		return nil, util.NewExtractorError(
+			util.ErrorFromStatus(res.StatusCode),
+			fmt.Errorf("failed to get video: %s", res.Status),
+		)
 	}
 	var response Response
 	err = sonic.ConfigFastest.NewDecoder(res.Body).Decode(&response)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			fmt.Errorf("failed to decode response: %w", err),
+		)
 	}
 	if response.Gif == nil {
-		return nil, fmt.Errorf("failed to get video: %s", res.Status)
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			fmt.Errorf("failed to get video: %s", res.Status),
+		)
 	}
 	return &response, nil
 }
@@ -1,6 +1,7 @@
 package redgifs
 
 import (
+	"context"
 	"fmt"
 	"govd/models"
 	"govd/util"
@@ -12,17 +13,17 @@ import (
 
 var accessToken *Token
 
-func GetAccessToken(session models.HTTPClient) (*Token, error) {
+func GetAccessToken(ctx context.Context, session models.HTTPClient) (*Token, error) {
 	if accessToken == nil || time.Now().Unix() >= accessToken.ExpiresIn {
-		if err := RefreshAccessToken(session); err != nil {
+		if err := RefreshAccessToken(ctx, session); err != nil {
 			return nil, err
 		}
 	}
 	return accessToken, nil
 }
 
-func RefreshAccessToken(session models.HTTPClient) error {
-	req, err := http.NewRequest(http.MethodGet, tokenEndpoint, nil)
+func RefreshAccessToken(ctx context.Context, session models.HTTPClient) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenEndpoint, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -33,7 +34,10 @@ func RefreshAccessToken(session models.HTTPClient) error {
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to get access token: %s", res.Status)
+		return util.NewExtractorError(
+			util.ErrorFromStatus(res.StatusCode),
+			fmt.Errorf("failed to get access token: %s", res.Status),
+		)
 	}
 	var token Token
 	err = sonic.ConfigFastest.NewDecoder(res.Body).Decode(&token)
@@ -38,7 +38,8 @@ func GetEmbedMediaList(ctx *models.DownloadContext) ([]*models.Media, error) {
 		"https://www.threads.net/@_/post/%s/embed",
 		ctx.MatchedContentID,
 	)
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx.Context,
 		http.MethodGet,
 		embedURL,
 		nil,
@@ -55,7 +56,10 @@ func GetEmbedMediaList(ctx *models.DownloadContext) ([]*models.Media, error) {
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get embed media: %s", res.Status)
+		return nil, util.NewExtractorError(
+			util.ErrorFromStatus(res.StatusCode),
+			fmt.Errorf("failed to get embed media: %s", res.Status),
+		)
 	}
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
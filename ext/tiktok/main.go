@@ -1,6 +1,7 @@
 package tiktok
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -79,7 +80,7 @@ var Extractor = &models.Extractor{
 func MediaListFromAPI(ctx *models.DownloadContext) ([]*models.Media, error) {
 	client := util.GetHTTPClient(ctx.Extractor.CodeName)
 	details, err := GetVideoAPI(
-		client, ctx.MatchedContentID)
+		ctx.Context, client, ctx.MatchedContentID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get from api: %w", err)
 	}
@@ -140,6 +141,7 @@ func MediaListFromAPI(ctx *models.DownloadContext) ([]*models.Media, error) {
 }
 
 func GetVideoAPI(
+	ctx context.Context,
 	client models.HTTPClient,
 	awemeID string,
 ) (*AwemeDetails, error) {
@@ -153,7 +155,8 @@ func GetVideoAPI(
 	}
 	postData := BuildPostData(awemeID)
 
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodPost,
 		apiURL,
 		postData,
@@ -176,7 +179,10 @@ func GetVideoAPI(
 	decoder := sonic.ConfigFastest.NewDecoder(resp.Body)
 	err = decoder.Decode(&data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			fmt.Errorf("failed to decode response: %w", err),
+		)
 	}
 	videoData, err := FindVideoData(data, awemeID)
 	if err != nil {
@@ -166,12 +166,19 @@ func FindVideoData(
 		return nil, util.ErrUnavailable
 	}
 	if resp.AwemeDetails == nil {
-		return nil, errors.New("aweme_details is nil")
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			errors.New("aweme_details is nil"),
+		)
 	}
 	for _, item := range resp.AwemeDetails {
 		if item.AwemeID == expectedAwemeID {
 			return &item, nil
 		}
 	}
-	return nil, errors.New("matching aweme_id not found")
+	// deleted videos are missing from the details
+	return nil, util.NewExtractorError(
+		util.ErrContentDeleted,
+		errors.New("matching aweme_id not found"),
+	)
 }
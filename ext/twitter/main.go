@@ -1,6 +1,7 @@
 package twitter
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -31,7 +32,7 @@ var ShortExtractor = &models.Extractor{
 
 	Run: func(ctx *models.DownloadContext) (*models.ExtractorResponse, error) {
 		client := util.GetHTTPClient(ctx.Extractor.CodeName)
-		req, err := http.NewRequest(http.MethodGet, ctx.MatchedContentURL, nil)
+		req, err := http.NewRequestWithContext(ctx.Context, http.MethodGet, ctx.MatchedContentURL, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create req: %w", err)
 		}
@@ -83,7 +84,7 @@ func MediaListFromAPI(ctx *models.DownloadContext) ([]*models.Media, error) {
 	client := util.GetHTTPClient(ctx.Extractor.CodeName)
 
 	tweetData, err := GetTweetAPI(
-		client, ctx.MatchedContentID)
+		ctx.Context, client, ctx.MatchedContentID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get tweet data: %w", err)
 	}
@@ -133,20 +134,28 @@ func MediaListFromAPI(ctx *models.DownloadContext) ([]*models.Media, error) {
 }
 
 func GetTweetAPI(
+	ctx context.Context,
 	client models.HTTPClient,
 	tweetID string,
 ) (*Tweet, error) {
-	cookies, err := util.ParseCookieFile("twitter.txt")
+	jar, err := util.GetCookieJar("twitter")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cookies: %w", err)
+	}
+	cookies, err := jar.Cookies()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cookies: %w", err)
 	}
 	headers := BuildAPIHeaders(cookies)
 	if headers == nil {
-		return nil, errors.New("failed to build headers. check cookies")
+		return nil, util.NewExtractorError(
+			util.ErrLoginRequired,
+			errors.New("failed to build headers. check cookies"),
+		)
 	}
 	query := BuildAPIQuery(tweetID)
 
-	req, err := http.NewRequest(http.MethodGet, apiEndpoint, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiEndpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create req: %w", err)
 	}
@@ -171,19 +180,39 @@ func GetTweetAPI(
 	}
 	defer resp.Body.Close()
 
+	err = jar.CheckResponse(resp)
+	if err != nil {
+		return nil, err
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("invalid response code: %s", resp.Status)
+		return nil, util.NewExtractorError(
+			util.ErrorFromStatus(resp.StatusCode),
+			fmt.Errorf("invalid response code: %s", resp.Status),
+		)
 	}
 
 	var apiResponse APIResponse
 	err = sonic.ConfigFastest.NewDecoder(resp.Body).Decode(&apiResponse)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			fmt.Errorf("failed to parse response: %w", err),
+		)
 	}
 
 	result := apiResponse.Data.TweetResult.Result
 	if result == nil {
-		return nil, errors.New("failed to get tweet result")
+		// deleted tweets have an empty result
+		return nil, util.NewExtractorError(
+			util.ErrContentDeleted,
+			errors.New("failed to get tweet result"),
+		)
+	}
+	if result.TypeName == "TweetUnavailable" || result.TypeName == "TweetTombstone" {
+		return nil, util.NewExtractorError(
+			UnavailableErrorKind(result.Reason),
+			fmt.Errorf("tweet is unavailable: %s", result.Reason),
+		)
 	}
 
 	var tweet *Tweet
@@ -193,7 +222,10 @@ func GetTweetAPI(
 	case result.Legacy != nil:
 		tweet = result.Legacy
 	default:
-		return nil, errors.New("failed to get tweet data")
+		return nil, util.NewExtractorError(
+			util.ErrUpstreamChanged,
+			errors.New("failed to get tweet data"),
+		)
 	}
 	return tweet, nil
 }
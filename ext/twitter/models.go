@@ -17,6 +17,7 @@ type TweetResult struct {
 	Source      string     `json:"source,omitempty"`
 	EditControl *EditInfo  `json:"edit_control,omitempty"`
 	TypeName    string     `json:"__typename,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
 }
 
 type EditInfo struct {
@@ -137,3 +137,20 @@ func extractResolution(url string) (int64, int64) {
 	}
 	return 0, 0
 }
+
+// UnavailableErrorKind maps the reason
+// of an unavailable tweet to its error
+func UnavailableErrorKind(reason string) *util.Error {
+	switch reason {
+	case "Protected":
+		return util.ErrContentPrivate
+	case "NsfwLoggedOut", "NsfwViewerIsUnderage", "NsfwViewerHasNoStatedAge":
+		return util.ErrAgeRestricted
+	case "Suspended", "Deleted":
+		return util.ErrContentDeleted
+	case "GeoBlocked", "Withheld":
+		return util.ErrGeoBlocked
+	default:
+		return util.ErrUnavailable
+	}
+}
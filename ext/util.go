@@ -1,16 +1,22 @@
 package ext
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
 	"sync"
 
 	"govd/models"
+	"govd/util"
 
 	"github.com/pkg/errors"
 )
 
+// ErrUnsupportedURL is returned by ExtractURL
+// when no extractor matches the url
+var ErrUnsupportedURL = errors.New("unsupported url")
+
 var (
 	maxRedirects = 5
 
@@ -31,7 +37,13 @@ func initExtractorMap() {
 	})
 }
 
-func CtxByURL(urlStr string) (*models.DownloadContext, error) {
+// CtxByURL matches the url with an extractor, following
+// redirects. the logger of ctx is passed to the extractor
+// with its code name and the content ID
+func CtxByURL(
+	ctx context.Context,
+	urlStr string,
+) (*models.DownloadContext, error) {
 	initExtractorMap()
 
 	var redirectCount int
@@ -74,7 +86,15 @@ func CtxByURL(urlStr string) (*models.DownloadContext, error) {
 			return nil, nil
 		}
 
-		ctx := &models.DownloadContext{
+		logger := util.GetLogger(ctx).With(
+			"extractor", extractor.CodeName,
+			"content_id", groups["id"],
+		)
+		// sticky proxies keep the same proxy for the
+		// content, from its api requests to the download
+		extractorCtx := util.WithProxyKey(ctx, groups["id"])
+		dlCtx := &models.DownloadContext{
+			Context:           util.WithLogger(extractorCtx, logger),
 			MatchedContentID:  groups["id"],
 			MatchedContentURL: groups["match"],
 			MatchedGroups:     groups,
@@ -82,16 +102,18 @@ func CtxByURL(urlStr string) (*models.DownloadContext, error) {
 		}
 
 		if !extractor.IsRedirect {
-			return ctx, nil
+			logger.Debug("matched extractor", "url", currentURL)
+			return dlCtx, nil
 		}
 
-		response, err := extractor.Run(ctx)
+		response, err := extractor.Run(dlCtx)
 		if err != nil {
 			return nil, err
 		}
 		if response.URL == "" {
 			return nil, errors.New("no URL found in response")
 		}
+		logger.Debug("following redirect", "url", response.URL)
 
 		currentURL = response.URL
 		redirectCount++
@@ -104,6 +126,25 @@ func CtxByURL(urlStr string) (*models.DownloadContext, error) {
 	return nil, fmt.Errorf("failed to extract from URL: %s", urlStr)
 }
 
+// ExtractURL matches the url with an extractor and runs it
+func ExtractURL(
+	ctx context.Context,
+	urlStr string,
+) (*models.DownloadContext, *models.ExtractorResponse, error) {
+	dlCtx, err := CtxByURL(ctx, urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dlCtx == nil || dlCtx.Extractor == nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedURL, urlStr)
+	}
+	response, err := dlCtx.Extractor.Run(dlCtx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("extractor fetch run failed: %w", err)
+	}
+	return dlCtx, response, nil
+}
+
 func ByCodeName(codeName string) *models.Extractor {
 	for _, extractor := range List {
 		if extractor.CodeName == codeName {
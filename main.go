@@ -1,31 +1,50 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"govd/api"
 	"govd/bot"
+	"govd/cli"
 	"govd/config"
 	"govd/database"
 	"govd/util"
+	"govd/util/metrics"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
 	_ "net/http/pprof" // profiling
 
 	"github.com/joho/godotenv"
 )
 
+const defaultShutdownTimeout = 25 * time.Second
+
 func main() {
+	// cli subcommands don't need telegram,
+	// database or a .env file
+	isCommand := len(os.Args) > 1 && cli.IsCommand(os.Args[1])
+
 	err := godotenv.Load()
-	if err != nil {
+	if err != nil && !isCommand {
 		log.Fatal("error loading .env file")
 	}
+	util.SetupLogger()
 	err = config.LoadExtractorConfigs()
 	if err != nil {
 		log.Fatalf("error loading extractor configs: %v", err)
 	}
 
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+	util.StartConfigWatcher()
+
 	profilerPort, err := strconv.Atoi(os.Getenv("PROFILER_PORT"))
 	if err == nil && profilerPort > 0 {
 		go func() {
@@ -34,6 +53,16 @@ func main() {
 		}()
 	}
 
+	apiPort, err := strconv.Atoi(os.Getenv("API_PORT"))
+	if err == nil && apiPort > 0 {
+		go api.Start(apiPort)
+	}
+
+	metricsPort, err := strconv.Atoi(os.Getenv("METRICS_PORT"))
+	if err == nil && metricsPort > 0 {
+		go metrics.Start(metricsPort)
+	}
+
 	util.CleanupDownloadsDir()
 	util.StartDownloadsCleanup()
 
@@ -46,5 +75,41 @@ func main() {
 
 	go bot.Start()
 
-	select {} // keep the main goroutine alive
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	log.Printf("received %s, shutting down", sig)
+	go func() {
+		<-signals
+		log.Fatal("received second signal, exiting")
+	}()
+	shutdown()
+}
+
+// shutdown lets running downloads finish
+// before releasing the resources they use
+func shutdown() {
+	timeout := defaultShutdownTimeout
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			log.Println("failed to parse SHUTDOWN_TIMEOUT env, using 25")
+		} else {
+			timeout = time.Duration(seconds) * time.Second
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	drained := bot.Stop(ctx)
+	util.FlushCookieJars()
+	database.Close()
+	if drained {
+		util.ClearDownloadsDir()
+	} else {
+		// files of the jobs still running are
+		// removed by the next periodic cleanup
+		log.Println("some downloads are still running, keeping downloads directory")
+	}
+	log.Println("shutdown complete")
 }
@@ -0,0 +1,18 @@
+package models
+
+import "govd/enums"
+
+type APIError struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+type APIExtractor struct {
+	Name       string                  `json:"name"`
+	CodeName   string                  `json:"code_name"`
+	Type       enums.ExtractorType     `json:"type"`
+	Category   enums.ExtractorCategory `json:"category"`
+	Host       []string                `json:"host"`
+	IsDRM      bool                    `json:"is_drm"`
+	IsRedirect bool                    `json:"is_redirect"`
+}
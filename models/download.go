@@ -1,15 +1,22 @@
 package models
 
-import "time"
+import (
+	"net/http"
+	"time"
+)
 
 type DownloadConfig struct {
-	ChunkSize       int           // size of each chunk in bytes
-	Concurrency     int           // maximum number of concurrent downloads
-	Timeout         time.Duration // timeout for individual HTTP requests
-	DownloadDir     string        // directory to save downloaded files
-	RetryAttempts   int           // number of retry attempts per chunk
-	RetryDelay      time.Duration // delay between retries
-	Remux           bool          // whether to remux the downloaded file with ffmpeg
-	ProgressUpdater func(float64) // optional function to report download progress
-	MaxInMemory     int           // maximum file size for in-memory downloads
+	ChunkSize       int                  // size of each chunk in bytes
+	Concurrency     int                  // maximum number of concurrent downloads
+	Timeout         time.Duration        // timeout for individual HTTP requests
+	DownloadDir     string               // directory to save downloaded files
+	RetryAttempts   int                  // number of retry attempts per chunk
+	RetryDelay      time.Duration        // delay between retries
+	Remux           bool                 // whether to remux the downloaded file with ffmpeg
+	ProgressUpdater func(float64, int64) // optional function to report download progress and downloaded bytes
+	MaxInMemory     int                  // maximum file size for in-memory downloads
+	ResumeKey       string               // optional stable key to resume partial downloads after a restart
+	HTTPClient      HTTPClient           // optional client used for requests, defaults to a shared one
+	Headers         map[string]string    // optional headers sent with each request
+	Cookies         []*http.Cookie       // optional cookies sent with each request
 }
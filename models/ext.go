@@ -19,8 +19,9 @@ type Extractor struct {
 }
 
 type ExtractorResponse struct {
-	MediaList []*Media
-	URL       string // redirected URL
+	MediaList []*Media `json:"media_list"`
+	URL       string   `json:"url,omitempty"`      // redirected URL
+	URLList   []string `json:"url_list,omitempty"` // content URLs (playlist extractors)
 }
 
 func (extractor *Extractor) NewMedia(
@@ -40,4 +41,12 @@ type ExtractorConfig struct {
 	NoProxy      string `yaml:"no_proxy"`
 	EdgeProxyURL string `yaml:"edge_proxy_url"`
 	Impersonate  bool   `yaml:"impersonate"`
+
+	Proxies             []string `yaml:"proxies"`
+	ProxyStrategy       string   `yaml:"proxy_strategy"`
+	ProxyCooldown       int      `yaml:"proxy_cooldown"` // seconds
+	ProxyHealthURL      string   `yaml:"proxy_health_url"`
+	ProxyHealthInterval int      `yaml:"proxy_health_interval"` // seconds
+
+	MaxConcurrency int `yaml:"max_concurrency"`
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PendingJob is a download requested with a message,
+// stored until it's done so it can be queued again
+// if the bot restarts
+type PendingJob struct {
+	ID        string `gorm:"primaryKey;size:16"`
+	UserID    int64  `gorm:"not null"`
+	ChatID    int64  `gorm:"not null"`
+	ChatType  string `gorm:"not null"`
+	MessageID int64  `gorm:"not null"`
+	URL       string `gorm:"not null"`
+	// replica that is running the job
+	Instance  string `gorm:"size:64;index"`
+	CreatedAt time.Time
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"slices"
@@ -74,6 +75,11 @@ type MediaFormat struct {
 	FileSize   int64            `json:"-"`
 	Plugins    []Plugin         `gorm:"-" json:"-"`
 
+	// headers and cookies required
+	// by the server to download the format
+	DownloadHeaders map[string]string `gorm:"-" json:"-"`
+	DownloadCookies []*http.Cookie    `gorm:"-" json:"-"`
+
 	CreatedAt time.Time      `json:"-"`
 	UpdatedAt time.Time      `json:"-"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
@@ -194,7 +200,7 @@ func (media *Media) GetAudioFromVideoFormat() *MediaFormat {
 		Type:       enums.MediaTypeAudio,
 		FormatID:   "AudioFromVideo",
 		URL:        videoFormat.URL,
-		AudioCodec: enums.MediaCodecAAC,
+		AudioCodec: enums.MediaCodecMP3,
 		Thumbnail:  videoFormat.Thumbnail,
 		Duration:   videoFormat.Duration,
 		Title:      videoFormat.Title,
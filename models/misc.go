@@ -1,8 +1,9 @@
 package models
 
 type SendMediaFormatsOptions struct {
-	IsStored bool
-	Caption  string
+	IsStored  bool
+	SkipCache bool // don't store file IDs, e.g. for split files
+	Caption   string
 }
 
 type Chunk struct {
@@ -1,3 +1,5 @@
 package models
 
-type Plugin = func(*DownloadedMedia) error
+import "context"
+
+type Plugin = func(context.Context, *DownloadedMedia) error
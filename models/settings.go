@@ -8,5 +8,8 @@ type GroupSettings struct {
 	ChatID          int64 `gorm:"primaryKey"`
 	NSFW            *bool `gorm:"default:false"`
 	Captions        *bool `gorm:"default:false"`
+	Transcode       *bool `gorm:"default:false"` // convert videos telegram can't stream
 	MediaGroupLimit int   `gorm:"default:10"`
+	RateLimit       int   `gorm:"default:0"` // requests per minute, 0 uses the default
+	DailyLimit      int   `gorm:"default:0"` // downloads per day, 0 means unlimited
 }
@@ -0,0 +1,10 @@
+package models
+
+// Usage tracks daily downloads of a user or chat.
+// ID is a user ID or a chat ID, Day is formatted as YYYY-MM-DD
+type Usage struct {
+	ID        int64  `gorm:"primaryKey;autoIncrement:false"`
+	Day       string `gorm:"primaryKey;size:10"`
+	Downloads int    `gorm:"default:0"`
+	Bytes     int64  `gorm:"default:0"`
+}
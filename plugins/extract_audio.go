@@ -0,0 +1,29 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"govd/models"
+	"govd/util/av"
+	"os"
+)
+
+func ExtractAudio(
+	ctx context.Context,
+	media *models.DownloadedMedia,
+) error {
+	videoFile := media.FilePath + ".video"
+	err := os.Rename(media.FilePath, videoFile)
+	if err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	defer os.Remove(videoFile)
+
+	err = av.AudioFromVideo(ctx, videoFile, media.FilePath)
+	if err != nil {
+		os.Remove(media.FilePath)
+		return fmt.Errorf("failed to extract audio from video: %w", err)
+	}
+
+	return nil
+}
@@ -4,4 +4,5 @@ import "govd/models"
 
 var List = []models.Plugin{
 	MergeAudio,
+	ExtractAudio,
 }
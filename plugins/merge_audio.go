@@ -10,16 +10,22 @@ import (
 	"github.com/pkg/errors"
 )
 
-func MergeAudio(media *models.DownloadedMedia) error {
+func MergeAudio(
+	ctx context.Context,
+	media *models.DownloadedMedia,
+) error {
 	audioFormat := media.Media.GetDefaultAudioFormat()
 	if audioFormat == nil {
 		return errors.New("no audio format found")
 	}
 
-	// download the audio file
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	config := util.FormatDownloadConfig(
+		nil,
+		media.Media.ExtractorCodeName,
+		audioFormat,
+	)
 
+	// download the audio file
 	var audioFile string
 	var err error
 
@@ -27,13 +33,13 @@ func MergeAudio(media *models.DownloadedMedia) error {
 		audioFile, err = util.DownloadFile(
 			ctx, audioFormat.URL,
 			audioFormat.GetFileName(),
-			nil,
+			config,
 		)
 	} else {
 		audioFile, err = util.DownloadFileWithSegments(
 			ctx, audioFormat.Segments,
 			audioFormat.GetFileName(),
-			nil,
+			config,
 		)
 	}
 	if err != nil {
@@ -41,6 +47,7 @@ func MergeAudio(media *models.DownloadedMedia) error {
 	}
 
 	err = av.MergeVideoWithAudio(
+		ctx,
 		media.FilePath,
 		audioFile,
 	)
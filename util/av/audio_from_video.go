@@ -1,11 +1,22 @@
 package av
 
 import (
+	"context"
+	"time"
+
+	"govd/util/metrics"
+
 	ffmpeg "github.com/u2takey/ffmpeg-go"
 )
 
-func AudioFromVideo(videoPath string, audioPath string) error {
-	err := ffmpeg.
+func AudioFromVideo(
+	ctx context.Context,
+	videoPath string,
+	audioPath string,
+) error {
+	defer metrics.ProcessingDuration.ObserveSince(time.Now(), "extract_audio")
+
+	stream := ffmpeg.
 		Input(videoPath).
 		Output(audioPath, ffmpeg.KwArgs{
 			"map": "a",
@@ -14,8 +25,8 @@ func AudioFromVideo(videoPath string, audioPath string) error {
 			"ab":  "128k",
 		}).
 		Silent(true).
-		OverWriteOutput().
-		Run()
+		OverWriteOutput()
+	err := runWithContext(ctx, stream)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,84 @@
+package av
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"govd/util/metrics"
+
+	"github.com/pkg/errors"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+const (
+	compressAudioBitrate    = 128 * 1000
+	minCompressVideoBitrate = 200 * 1000
+)
+
+var ErrBitrateTooLow = errors.New("target bitrate is too low")
+
+// CompressVideo re-encodes the video to H.264/AAC
+// with a two-pass encoding targeting the given size
+func CompressVideo(
+	ctx context.Context,
+	inputPath string,
+	outputPath string,
+	targetSize int64,
+	duration int64,
+) error {
+	defer metrics.ProcessingDuration.ObserveSince(time.Now(), "compress")
+
+	if duration <= 0 {
+		return errors.New("unknown video duration")
+	}
+	videoBitrate := targetSize*8/duration - compressAudioBitrate
+	if videoBitrate < minCompressVideoBitrate {
+		return ErrBitrateTooLow
+	}
+
+	passLogFile := outputPath + ".passlog"
+	defer os.Remove(passLogFile + "-0.log")
+	defer os.Remove(passLogFile + "-0.log.mbtree")
+
+	firstPass := ffmpeg.
+		Input(inputPath).
+		Output(os.DevNull, ffmpeg.KwArgs{
+			"c:v":         "libx264",
+			"b:v":         videoBitrate,
+			"preset":      "veryfast",
+			"pass":        1,
+			"passlogfile": passLogFile,
+			"an":          nil,
+			"f":           "null",
+		}).
+		Silent(true).
+		OverWriteOutput()
+	err := runWithContext(ctx, firstPass)
+	if err != nil {
+		return fmt.Errorf("first pass failed: %w", err)
+	}
+
+	secondPass := ffmpeg.
+		Input(inputPath).
+		Output(outputPath, ffmpeg.KwArgs{
+			"c:v":         "libx264",
+			"b:v":         videoBitrate,
+			"preset":      "veryfast",
+			"pass":        2,
+			"passlogfile": passLogFile,
+			"pix_fmt":     "yuv420p",
+			"c:a":         "aac",
+			"b:a":         compressAudioBitrate,
+			"movflags":    "+faststart",
+		}).
+		Silent(true).
+		OverWriteOutput()
+	err = runWithContext(ctx, secondPass)
+	if err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("second pass failed: %w", err)
+	}
+	return nil
+}
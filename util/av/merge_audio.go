@@ -1,16 +1,23 @@
 package av
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"time"
+
+	"govd/util/metrics"
 
 	ffmpeg "github.com/u2takey/ffmpeg-go"
 )
 
 func MergeVideoWithAudio(
+	ctx context.Context,
 	videoFile string,
 	audioFile string,
 ) error {
+	defer metrics.ProcessingDuration.ObserveSince(time.Now(), "merge_audio")
+
 	tempFileName := videoFile + ".temp"
 	outputFile := videoFile
 
@@ -24,7 +31,7 @@ func MergeVideoWithAudio(
 	videoStream := ffmpeg.Input(tempFileName)
 	audioStream := ffmpeg.Input(audioFile)
 
-	err = ffmpeg.Output(
+	stream := ffmpeg.Output(
 		[]*ffmpeg.Stream{videoStream, audioStream},
 		outputFile,
 		ffmpeg.KwArgs{
@@ -34,9 +41,8 @@ func MergeVideoWithAudio(
 			"c:a":      "copy",
 		}).
 		Silent(true).
-		OverWriteOutput().
-		Run()
-
+		OverWriteOutput()
+	err = runWithContext(ctx, stream)
 	if err != nil {
 		os.Remove(outputFile)
 		return fmt.Errorf("failed to merge files: %w", err)
@@ -1,17 +1,24 @@
 package av
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"time"
+
+	"govd/util/metrics"
 
 	"github.com/pkg/errors"
 	ffmpeg "github.com/u2takey/ffmpeg-go"
 )
 
 func MergeSegments(
+	ctx context.Context,
 	segmentPaths []string,
 	outputPath string,
 ) (string, error) {
+	defer metrics.ProcessingDuration.ObserveSince(time.Now(), "merge_segments")
+
 	if len(segmentPaths) == 0 {
 		return "", errors.New("no segments to merge")
 	}
@@ -26,7 +33,7 @@ func MergeSegments(
 		fmt.Fprintf(listFile, "file '%s'\n", segmentPath)
 	}
 
-	err = ffmpeg.
+	stream := ffmpeg.
 		Input(listFilePath, ffmpeg.KwArgs{
 			"f":                  "concat",
 			"safe":               "0",
@@ -37,8 +44,8 @@ func MergeSegments(
 			"movflags": "+faststart",
 		}).
 		Silent(true).
-		OverWriteOutput().
-		Run()
+		OverWriteOutput()
+	err = runWithContext(ctx, stream)
 	if err != nil {
 		os.Remove(outputPath)
 		return "", fmt.Errorf("failed to merge segments: %w", err)
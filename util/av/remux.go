@@ -4,12 +4,17 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"govd/util/metrics"
 
 	"github.com/asticode/go-astiav"
 	"github.com/pkg/errors"
 )
 
 func RemuxFile(inputFile string) error {
+	defer metrics.ProcessingDuration.ObserveSince(time.Now(), "remux")
+
 	astiav.SetLogLevel(astiav.LogLevelQuiet)
 
 	ext := strings.ToLower(filepath.Ext(inputFile))
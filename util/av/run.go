@@ -0,0 +1,35 @@
+package av
+
+import (
+	"context"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// runWithContext runs the ffmpeg command
+// and kills it if ctx is done before it exits
+func runWithContext(
+	ctx context.Context,
+	stream *ffmpeg.Stream,
+) error {
+	cmd := stream.Compile()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	defer close(exited)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cmd.Process.Kill()
+		case <-exited:
+		}
+	}()
+
+	err := cmd.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
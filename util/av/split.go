@@ -0,0 +1,69 @@
+package av
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"govd/util/metrics"
+
+	"github.com/pkg/errors"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// SplitFile splits the file into parts of the given
+// duration (in seconds) without re-encoding.
+// parts are cut on keyframes, so their duration is approximate
+func SplitFile(
+	ctx context.Context,
+	inputPath string,
+	partDuration int64,
+) ([]string, error) {
+	defer metrics.ProcessingDuration.ObserveSince(time.Now(), "split")
+
+	if partDuration <= 0 {
+		return nil, errors.New("invalid part duration")
+	}
+	ext := filepath.Ext(inputPath)
+	prefix := strings.TrimSuffix(inputPath, ext) + ".part"
+	pattern := prefix + "%03d" + ext
+
+	kwArgs := ffmpeg.KwArgs{
+		"c":                "copy",
+		"map":              "0",
+		"f":                "segment",
+		"segment_time":     partDuration,
+		"reset_timestamps": 1,
+	}
+	switch strings.ToLower(ext) {
+	case ".mp4", ".m4a", ".mov":
+		kwArgs["segment_format_options"] = "movflags=+faststart"
+	}
+
+	stream := ffmpeg.
+		Input(inputPath).
+		Output(pattern, kwArgs).
+		Silent(true).
+		OverWriteOutput()
+	err := runWithContext(ctx, stream)
+
+	parts, globErr := filepath.Glob(prefix + "*" + ext)
+	if globErr != nil {
+		return nil, fmt.Errorf("failed to list parts: %w", globErr)
+	}
+	if err != nil {
+		for _, part := range parts {
+			os.Remove(part)
+		}
+		return nil, fmt.Errorf("failed to split file: %w", err)
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("no parts created")
+	}
+	sort.Strings(parts)
+	return parts, nil
+}
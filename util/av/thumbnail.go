@@ -6,11 +6,15 @@ import (
 	"os"
 	"time"
 
+	"govd/util/metrics"
+
 	"github.com/asticode/go-astiav"
 	"github.com/pkg/errors"
 )
 
 func ExtractVideoThumbnail(videoPath string, imagePath string) error {
+	defer metrics.ProcessingDuration.ObserveSince(time.Now(), "thumbnail")
+
 	astiav.SetLogLevel(astiav.LogLevelQuiet)
 
 	formatCtx := astiav.AllocFormatContext()
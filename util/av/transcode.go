@@ -0,0 +1,484 @@
+package av
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"govd/util/metrics"
+
+	"github.com/asticode/go-astiav"
+	"github.com/pkg/errors"
+)
+
+const (
+	transcodeVideoCRF     = "23"
+	transcodeVideoPreset  = "veryfast"
+	transcodeAudioBitrate = 128 * 1000
+)
+
+type transcoder struct {
+	inputCtx  *astiav.FormatContext
+	outputCtx *astiav.FormatContext
+	streams   map[int]*transcodeStream
+	cleanups  []func()
+}
+
+type transcodeStream struct {
+	outStream *astiav.Stream
+	mediaType astiav.MediaType
+	decCtx    *astiav.CodecContext
+	encCtx    *astiav.CodecContext
+	decFrame  *astiav.Frame
+	encPacket *astiav.Packet
+
+	// video only, set when the decoded
+	// frames need to be converted
+	scaleCtx    *astiav.SoftwareScaleContext
+	scaledFrame *astiav.Frame
+
+	// audio only
+	resampleCtx    *astiav.SoftwareResampleContext
+	resampledFrame *astiav.Frame
+	fifo           *astiav.AudioFifo
+	fifoFrame      *astiav.Frame
+	nextPts        int64
+}
+
+// TranscodeToAVC re-encodes the first video and audio
+// streams of the input file to H.264/AAC in an mp4 container.
+// encoding runs on the cpu, so it can take a while
+func TranscodeToAVC(
+	ctx context.Context,
+	inputPath string,
+	outputPath string,
+) error {
+	defer metrics.ProcessingDuration.ObserveSince(time.Now(), "transcode")
+
+	astiav.SetLogLevel(astiav.LogLevelQuiet)
+
+	t := &transcoder{
+		streams: make(map[int]*transcodeStream),
+	}
+	err := t.run(ctx, inputPath, outputPath)
+	t.close()
+	if err != nil {
+		os.Remove(outputPath)
+		return err
+	}
+	return nil
+}
+
+func (t *transcoder) run(
+	ctx context.Context,
+	inputPath string,
+	outputPath string,
+) error {
+	if err := t.openInput(inputPath); err != nil {
+		return err
+	}
+	if err := t.openOutput(outputPath); err != nil {
+		return err
+	}
+
+	packet := astiav.AllocPacket()
+	defer packet.Free()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := t.inputCtx.ReadFrame(packet); err != nil {
+			if errors.Is(err, astiav.ErrEof) {
+				break
+			}
+			return fmt.Errorf("error reading frame: %w", err)
+		}
+		stream, ok := t.streams[packet.StreamIndex()]
+		if !ok {
+			packet.Unref()
+			continue
+		}
+		err := t.decode(stream, packet)
+		packet.Unref()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, stream := range t.streams {
+		if err := t.flush(stream); err != nil {
+			return err
+		}
+	}
+	if err := t.outputCtx.WriteTrailer(); err != nil {
+		return fmt.Errorf("failed to write trailer: %w", err)
+	}
+	return nil
+}
+
+// onClose registers a function to release
+// a resource, called in reverse order
+func (t *transcoder) onClose(fn func()) {
+	t.cleanups = append(t.cleanups, fn)
+}
+
+func (t *transcoder) close() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func (t *transcoder) openInput(inputPath string) error {
+	t.inputCtx = astiav.AllocFormatContext()
+	if t.inputCtx == nil {
+		return errors.New("failed to alloc input format context")
+	}
+	t.onClose(t.inputCtx.Free)
+
+	if err := t.inputCtx.OpenInput(inputPath, nil, nil); err != nil {
+		return fmt.Errorf("failed to open input: %w", err)
+	}
+	t.onClose(t.inputCtx.CloseInput)
+
+	if err := t.inputCtx.FindStreamInfo(nil); err != nil {
+		return fmt.Errorf("failed to find stream info: %w", err)
+	}
+	return nil
+}
+
+func (t *transcoder) openOutput(outputPath string) error {
+	outputCtx, err := astiav.AllocOutputFormatContext(nil, "mp4", outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to alloc output format context: %w", err)
+	}
+	t.outputCtx = outputCtx
+	t.onClose(outputCtx.Free)
+
+	var hasVideo, hasAudio bool
+	for idx, inStream := range t.inputCtx.Streams() {
+		switch inStream.CodecParameters().MediaType() {
+		case astiav.MediaTypeVideo:
+			if hasVideo {
+				continue
+			}
+			hasVideo = true
+		case astiav.MediaTypeAudio:
+			if hasAudio {
+				continue
+			}
+			hasAudio = true
+		default:
+			continue
+		}
+		stream, err := t.addStream(inStream)
+		if err != nil {
+			return err
+		}
+		t.streams[idx] = stream
+	}
+	if !hasVideo {
+		return errors.New("no video stream found")
+	}
+
+	if !outputCtx.OutputFormat().Flags().Has(astiav.IOFormatFlagNofile) {
+		ioCtx, err := astiav.OpenIOContext(outputPath, astiav.NewIOContextFlags(astiav.IOContextFlagWrite), nil, nil)
+		if err != nil {
+			return fmt.Errorf("failed to open output IO context: %w", err)
+		}
+		t.onClose(func() { ioCtx.Close() })
+		outputCtx.SetPb(ioCtx)
+	}
+
+	options := astiav.NewDictionary()
+	defer options.Free()
+	// allow playback before the whole file is downloaded
+	options.Set("movflags", "+faststart", astiav.NewDictionaryFlags())
+	if err := outputCtx.WriteHeader(options); err != nil {
+		return fmt.Errorf("failed to write output header: %w", err)
+	}
+	return nil
+}
+
+func (t *transcoder) addStream(inStream *astiav.Stream) (*transcodeStream, error) {
+	params := inStream.CodecParameters()
+	stream := &transcodeStream{mediaType: params.MediaType()}
+
+	decoder := astiav.FindDecoder(params.CodecID())
+	if decoder == nil {
+		return nil, fmt.Errorf("no decoder found for codec %v", params.CodecID())
+	}
+	stream.decCtx = astiav.AllocCodecContext(decoder)
+	if stream.decCtx == nil {
+		return nil, errors.New("failed to alloc decoder context")
+	}
+	t.onClose(stream.decCtx.Free)
+	if err := params.ToCodecContext(stream.decCtx); err != nil {
+		return nil, fmt.Errorf("failed to copy codec parameters: %w", err)
+	}
+	if stream.mediaType == astiav.MediaTypeVideo {
+		stream.decCtx.SetFramerate(t.inputCtx.GuessFrameRate(inStream, nil))
+	}
+	if err := stream.decCtx.Open(decoder, nil); err != nil {
+		return nil, fmt.Errorf("failed to open decoder: %w", err)
+	}
+
+	encoderName := "libx264"
+	if stream.mediaType == astiav.MediaTypeAudio {
+		encoderName = "aac"
+	}
+	encoder := astiav.FindEncoderByName(encoderName)
+	if encoder == nil {
+		return nil, fmt.Errorf("encoder %s not found", encoderName)
+	}
+	stream.encCtx = astiav.AllocCodecContext(encoder)
+	if stream.encCtx == nil {
+		return nil, errors.New("failed to alloc encoder context")
+	}
+	t.onClose(stream.encCtx.Free)
+
+	options := astiav.NewDictionary()
+	defer options.Free()
+	if stream.mediaType == astiav.MediaTypeVideo {
+		if err := t.setupVideoEncoder(stream, inStream, options); err != nil {
+			return nil, err
+		}
+	} else {
+		t.setupAudioEncoder(stream)
+	}
+	if t.outputCtx.OutputFormat().Flags().Has(astiav.IOFormatFlagGlobalheader) {
+		stream.encCtx.SetFlags(stream.encCtx.Flags().Add(astiav.CodecContextFlagGlobalHeader))
+	}
+	if err := stream.encCtx.Open(encoder, options); err != nil {
+		return nil, fmt.Errorf("failed to open encoder: %w", err)
+	}
+	if stream.mediaType == astiav.MediaTypeAudio {
+		// the frame size is only known once the encoder is open
+		stream.fifo = astiav.AllocAudioFifo(
+			stream.encCtx.SampleFormat(),
+			stream.encCtx.ChannelLayout().Channels(),
+			stream.encCtx.FrameSize(),
+		)
+		t.onClose(stream.fifo.Free)
+	}
+
+	stream.outStream = t.outputCtx.NewStream(nil)
+	if stream.outStream == nil {
+		return nil, errors.New("failed to create new stream in output context")
+	}
+	if err := stream.outStream.CodecParameters().FromCodecContext(stream.encCtx); err != nil {
+		return nil, fmt.Errorf("failed to copy encoder parameters: %w", err)
+	}
+	stream.outStream.SetTimeBase(stream.encCtx.TimeBase())
+
+	stream.decFrame = astiav.AllocFrame()
+	t.onClose(stream.decFrame.Free)
+	stream.encPacket = astiav.AllocPacket()
+	t.onClose(stream.encPacket.Free)
+	return stream, nil
+}
+
+func (t *transcoder) setupVideoEncoder(
+	stream *transcodeStream,
+	inStream *astiav.Stream,
+	options *astiav.Dictionary,
+) error {
+	decCtx := stream.decCtx
+	encCtx := stream.encCtx
+
+	// yuv420p requires even dimensions
+	width := decCtx.Width() &^ 1
+	height := decCtx.Height() &^ 1
+	pixelFormat := astiav.PixelFormatYuv420P
+
+	encCtx.SetWidth(width)
+	encCtx.SetHeight(height)
+	encCtx.SetPixelFormat(pixelFormat)
+	encCtx.SetSampleAspectRatio(decCtx.SampleAspectRatio())
+	encCtx.SetTimeBase(inStream.TimeBase())
+	encCtx.SetFramerate(decCtx.Framerate())
+	options.Set("crf", transcodeVideoCRF, astiav.NewDictionaryFlags())
+	options.Set("preset", transcodeVideoPreset, astiav.NewDictionaryFlags())
+
+	if decCtx.PixelFormat() == pixelFormat &&
+		decCtx.Width() == width &&
+		decCtx.Height() == height {
+		return nil
+	}
+	// 10-bit and other formats are not supported
+	// by most players, convert them to yuv420p
+	scaleCtx, err := astiav.CreateSoftwareScaleContext(
+		decCtx.Width(), decCtx.Height(), decCtx.PixelFormat(),
+		width, height, pixelFormat,
+		astiav.NewSoftwareScaleContextFlags(astiav.SoftwareScaleContextFlagBilinear),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create scale context: %w", err)
+	}
+	stream.scaleCtx = scaleCtx
+	t.onClose(scaleCtx.Free)
+
+	stream.scaledFrame = astiav.AllocFrame()
+	t.onClose(stream.scaledFrame.Free)
+	if err := scaleCtx.PrepareDestinationFrameForScaling(stream.scaledFrame); err != nil {
+		return fmt.Errorf("failed to prepare scaled frame: %w", err)
+	}
+	return nil
+}
+
+func (t *transcoder) setupAudioEncoder(stream *transcodeStream) {
+	encCtx := stream.encCtx
+	sampleRate := stream.decCtx.SampleRate()
+
+	encCtx.SetChannelLayout(astiav.ChannelLayoutStereo)
+	encCtx.SetSampleFormat(astiav.SampleFormatFltp)
+	encCtx.SetSampleRate(sampleRate)
+	encCtx.SetTimeBase(astiav.NewRational(1, sampleRate))
+	encCtx.SetBitRate(transcodeAudioBitrate)
+
+	stream.resampleCtx = astiav.AllocSoftwareResampleContext()
+	t.onClose(stream.resampleCtx.Free)
+	stream.resampledFrame = astiav.AllocFrame()
+	t.onClose(stream.resampledFrame.Free)
+	stream.fifoFrame = astiav.AllocFrame()
+	t.onClose(stream.fifoFrame.Free)
+}
+
+// decode sends the packet to the decoder and encodes
+// every frame it returns. a nil packet flushes the decoder
+func (t *transcoder) decode(
+	stream *transcodeStream,
+	packet *astiav.Packet,
+) error {
+	if err := stream.decCtx.SendPacket(packet); err != nil {
+		return fmt.Errorf("failed to send packet to decoder: %w", err)
+	}
+	for {
+		err := stream.decCtx.ReceiveFrame(stream.decFrame)
+		if errors.Is(err, astiav.ErrEagain) || errors.Is(err, astiav.ErrEof) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode frame: %w", err)
+		}
+		if stream.mediaType == astiav.MediaTypeVideo {
+			err = t.processVideoFrame(stream)
+		} else {
+			err = t.processAudioFrame(stream)
+		}
+		stream.decFrame.Unref()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (t *transcoder) processVideoFrame(stream *transcodeStream) error {
+	frame := stream.decFrame
+	if stream.scaleCtx != nil {
+		if err := stream.scaledFrame.MakeWritable(); err != nil {
+			return fmt.Errorf("failed to make frame writable: %w", err)
+		}
+		if err := stream.scaleCtx.ScaleFrame(frame, stream.scaledFrame); err != nil {
+			return fmt.Errorf("failed to scale frame: %w", err)
+		}
+		stream.scaledFrame.SetPts(frame.Pts())
+		frame = stream.scaledFrame
+	}
+	// let the encoder choose the frame type
+	frame.SetPictureType(astiav.PictureTypeNone)
+	return t.encode(stream, frame)
+}
+
+func (t *transcoder) processAudioFrame(stream *transcodeStream) error {
+	encCtx := stream.encCtx
+	resampled := stream.resampledFrame
+	resampled.SetChannelLayout(encCtx.ChannelLayout())
+	resampled.SetSampleFormat(encCtx.SampleFormat())
+	resampled.SetSampleRate(encCtx.SampleRate())
+	defer resampled.Unref()
+
+	if err := stream.resampleCtx.ConvertFrame(stream.decFrame, resampled); err != nil {
+		return fmt.Errorf("failed to resample frame: %w", err)
+	}
+	if resampled.NbSamples() > 0 {
+		if _, err := stream.fifo.Write(resampled); err != nil {
+			return fmt.Errorf("failed to write samples: %w", err)
+		}
+	}
+	return t.encodeAudioFifo(stream, false)
+}
+
+// encodeAudioFifo encodes the buffered samples in frames
+// of the size required by the encoder. when flushing,
+// the remaining samples are sent as a shorter frame
+func (t *transcoder) encodeAudioFifo(
+	stream *transcodeStream,
+	flush bool,
+) error {
+	encCtx := stream.encCtx
+	frameSize := encCtx.FrameSize()
+	for stream.fifo.Size() >= frameSize || (flush && stream.fifo.Size() > 0) {
+		frame := stream.fifoFrame
+		frame.SetNbSamples(min(frameSize, stream.fifo.Size()))
+		frame.SetChannelLayout(encCtx.ChannelLayout())
+		frame.SetSampleFormat(encCtx.SampleFormat())
+		frame.SetSampleRate(encCtx.SampleRate())
+		if err := frame.AllocBuffer(0); err != nil {
+			return fmt.Errorf("failed to alloc audio frame: %w", err)
+		}
+		if _, err := stream.fifo.Read(frame); err != nil {
+			frame.Unref()
+			return fmt.Errorf("failed to read samples: %w", err)
+		}
+		frame.SetPts(stream.nextPts)
+		stream.nextPts += int64(frame.NbSamples())
+
+		err := t.encode(stream, frame)
+		frame.Unref()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encode sends the frame to the encoder and writes every
+// packet it returns. a nil frame flushes the encoder
+func (t *transcoder) encode(
+	stream *transcodeStream,
+	frame *astiav.Frame,
+) error {
+	if err := stream.encCtx.SendFrame(frame); err != nil {
+		return fmt.Errorf("failed to send frame to encoder: %w", err)
+	}
+	packet := stream.encPacket
+	for {
+		err := stream.encCtx.ReceivePacket(packet)
+		if errors.Is(err, astiav.ErrEagain) || errors.Is(err, astiav.ErrEof) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to encode frame: %w", err)
+		}
+		packet.RescaleTs(stream.encCtx.TimeBase(), stream.outStream.TimeBase())
+		packet.SetStreamIndex(stream.outStream.Index())
+		err = t.outputCtx.WriteInterleavedFrame(packet)
+		packet.Unref()
+		if err != nil {
+			return fmt.Errorf("error writing frame: %w", err)
+		}
+	}
+}
+
+func (t *transcoder) flush(stream *transcodeStream) error {
+	if err := t.decode(stream, nil); err != nil {
+		return err
+	}
+	if stream.mediaType == astiav.MediaTypeAudio {
+		if err := t.encodeAudioFifo(stream, true); err != nil {
+			return err
+		}
+	}
+	return t.encode(stream, nil)
+}
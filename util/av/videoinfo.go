@@ -41,3 +41,25 @@ func GetVideoInfo(filePath string) (int64, int64, int64) {
 
 	return durationSeconds, width, height
 }
+
+// GetDuration returns the duration in seconds
+// of any media file, or 0 if it can't be read
+func GetDuration(filePath string) int64 {
+	astiav.SetLogLevel(astiav.LogLevelQuiet)
+
+	formatCtx := astiav.AllocFormatContext()
+	if formatCtx == nil {
+		return 0
+	}
+	defer formatCtx.Free()
+
+	if err := formatCtx.OpenInput(filePath, nil, nil); err != nil {
+		return 0
+	}
+	defer formatCtx.CloseInput()
+
+	if err := formatCtx.FindStreamInfo(nil); err != nil {
+		return 0
+	}
+	return formatCtx.Duration() / int64(astiav.TimeBase)
+}
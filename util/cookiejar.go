@@ -0,0 +1,426 @@
+package util
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCookieQuarantine = 30 * time.Minute
+	// consecutive failures double the quarantine, up to 16 times
+	maxCookieQuarantineShift = 4
+	// minimum time between writes of a jar file, since
+	// many responses refresh the cookies of a session
+	cookieFlushInterval = 30 * time.Second
+)
+
+// CookieJar is the cookie file of an account. jars of
+// the same platform are rotated, and the ones hitting
+// login walls are quarantined for a while
+type CookieJar struct {
+	platform string
+	fileName string
+
+	// guarded by cookieJarsMu
+	uses             int
+	failures         int
+	quarantinedUntil time.Time
+	quarantinedAt    time.Time
+	lastError        string
+
+	// serializes writes to the file
+	fileMu sync.Mutex
+	// cookies changed since the last write, and the
+	// modification time of the file they were read from
+	pendingCookies []*http.Cookie
+	pendingModTime time.Time
+	flushTimer     *time.Timer
+	lastFlush      time.Time
+
+	// redacted cookies used to replay fixtures
+	replayCookies []*http.Cookie
+}
+
+type CookieJarStats struct {
+	Platform         string
+	FileName         string
+	Uses             int
+	Failures         int
+	QuarantinedUntil time.Time
+	LastError        string
+}
+
+type cookieJarPool struct {
+	jars map[string]*CookieJar
+	next int
+}
+
+var (
+	cookieJarPools = make(map[string]*cookieJarPool)
+	cookieJarsMu   sync.Mutex
+)
+
+// GetCookieJar returns the next cookie jar of the platform,
+// picked in round robin between cookies/<platform>.txt and
+// cookies/<platform>/*.txt files. if all jars are in
+// quarantine, the one whose quarantine expires first is used
+func GetCookieJar(platform string) (*CookieJar, error) {
+	if GetFixtureMode() == FixtureModeReplay {
+		return replayCookieJar(platform)
+	}
+	fileNames := listCookieFiles(platform)
+	if len(fileNames) == 0 {
+		return nil, fmt.Errorf("failed to open cookie file: no cookies found for %s", platform)
+	}
+
+	cookieJarsMu.Lock()
+	defer cookieJarsMu.Unlock()
+
+	pool, ok := cookieJarPools[platform]
+	if !ok {
+		pool = &cookieJarPool{jars: make(map[string]*CookieJar)}
+		cookieJarPools[platform] = pool
+	}
+	for fileName := range pool.jars {
+		if !slices.Contains(fileNames, fileName) {
+			delete(pool.jars, fileName)
+		}
+	}
+
+	now := time.Now()
+	jars := make([]*CookieJar, 0, len(fileNames))
+	available := make([]*CookieJar, 0, len(fileNames))
+	for _, fileName := range fileNames {
+		jar, ok := pool.jars[fileName]
+		if !ok {
+			jar = &CookieJar{
+				platform: platform,
+				fileName: fileName,
+			}
+			pool.jars[fileName] = jar
+		}
+		if now.Before(jar.quarantinedUntil) && jar.isReplaced() {
+			slog.Info("cookie jar changed, lifting quarantine", "file", fileName)
+			jar.quarantinedUntil = time.Time{}
+			jar.failures = 0
+		}
+		jars = append(jars, jar)
+		if now.After(jar.quarantinedUntil) {
+			available = append(available, jar)
+		}
+	}
+
+	var jar *CookieJar
+	if len(available) == 0 {
+		jar = jars[0]
+		for _, other := range jars[1:] {
+			if other.quarantinedUntil.Before(jar.quarantinedUntil) {
+				jar = other
+			}
+		}
+	} else {
+		pool.next = (pool.next + 1) % len(available)
+		jar = available[pool.next]
+	}
+	jar.uses++
+	return jar, nil
+}
+
+// GetCookieJarStats returns the state of
+// every cookie jar used since the start
+func GetCookieJarStats() []*CookieJarStats {
+	cookieJarsMu.Lock()
+	defer cookieJarsMu.Unlock()
+
+	var stats []*CookieJarStats
+	for _, pool := range cookieJarPools {
+		for _, jar := range pool.jars {
+			stats = append(stats, &CookieJarStats{
+				Platform:         jar.platform,
+				FileName:         jar.fileName,
+				Uses:             jar.uses,
+				Failures:         jar.failures,
+				QuarantinedUntil: jar.quarantinedUntil,
+				LastError:        jar.lastError,
+			})
+		}
+	}
+	slices.SortFunc(stats, func(a, b *CookieJarStats) int {
+		return strings.Compare(a.FileName, b.FileName)
+	})
+	return stats
+}
+
+// Name returns the file of the jar,
+// relative to the cookies directory
+func (jar *CookieJar) Name() string {
+	return jar.fileName
+}
+
+func (jar *CookieJar) Cookies() ([]*http.Cookie, error) {
+	if jar.replayCookies != nil {
+		return jar.replayCookies, nil
+	}
+	jar.fileMu.Lock()
+	pending := jar.pendingCookies
+	jar.fileMu.Unlock()
+	if pending != nil {
+		return pending, nil
+	}
+	return ParseCookieFile(jar.fileName)
+}
+
+// CheckResponse quarantines the jar if the response is a
+// login wall. otherwise the cookies set by the response
+// are saved to the jar file, keeping the session fresh
+func (jar *CookieJar) CheckResponse(resp *http.Response) error {
+	if isLoginWall(resp) {
+		jar.Quarantine(resp.Status)
+		return NewExtractorError(
+			ErrLoginRequired,
+			fmt.Errorf("cookie jar %s hit a login wall: %s", jar.fileName, resp.Status),
+		)
+	}
+	if resp.StatusCode < http.StatusBadRequest {
+		cookieJarsMu.Lock()
+		jar.failures = 0
+		cookieJarsMu.Unlock()
+	}
+	jar.saveCookies(resp)
+	return nil
+}
+
+// Quarantine stops using the jar until its quarantine
+// expires or its file is replaced
+func (jar *CookieJar) Quarantine(reason string) {
+	cookieJarsMu.Lock()
+	defer cookieJarsMu.Unlock()
+
+	quarantine := defaultCookieQuarantine << min(jar.failures, maxCookieQuarantineShift)
+	jar.failures++
+	jar.quarantinedUntil = time.Now().Add(quarantine)
+	jar.quarantinedAt = time.Now()
+	jar.lastError = reason
+	slog.Warn(
+		"quarantined cookie jar",
+		"file", jar.fileName,
+		"duration", quarantine,
+		"reason", reason,
+	)
+}
+
+// isReplaced reports whether the jar file
+// was modified after it was quarantined
+func (jar *CookieJar) isReplaced() bool {
+	info, err := os.Stat(filepath.Join(CookiesDir, jar.fileName))
+	if err != nil {
+		return false
+	}
+	return info.ModTime().After(jar.quarantinedAt)
+}
+
+func (jar *CookieJar) saveCookies(resp *http.Response) {
+	setCookies := resp.Cookies()
+	if len(setCookies) == 0 || resp.Request == nil || jar.fileName == "" {
+		return
+	}
+	cookieJarsMu.Lock()
+	quarantined := time.Now().Before(jar.quarantinedUntil)
+	cookieJarsMu.Unlock()
+	if quarantined {
+		// writing the file would lift the quarantine
+		return
+	}
+	jar.fileMu.Lock()
+	defer jar.fileMu.Unlock()
+
+	cookies := jar.pendingCookies
+	if cookies == nil {
+		info, err := os.Stat(filepath.Join(CookiesDir, jar.fileName))
+		if err != nil {
+			return
+		}
+		cookies, err = ParseCookieFile(jar.fileName)
+		if err != nil {
+			return
+		}
+		jar.pendingModTime = info.ModTime()
+	}
+	cookies, changed := mergeCookies(cookies, setCookies, resp.Request.URL.Hostname())
+	if !changed {
+		return
+	}
+	jar.pendingCookies = cookies
+
+	wait := cookieFlushInterval - time.Since(jar.lastFlush)
+	if wait <= 0 {
+		jar.writeCookies()
+		return
+	}
+	if jar.flushTimer == nil {
+		jar.flushTimer = time.AfterFunc(wait, jar.flush)
+	}
+}
+
+// flush writes the cookies changed since the last write
+func (jar *CookieJar) flush() {
+	jar.fileMu.Lock()
+	defer jar.fileMu.Unlock()
+
+	if jar.flushTimer != nil {
+		jar.flushTimer.Stop()
+		jar.flushTimer = nil
+	}
+	jar.writeCookies()
+}
+
+// writeCookies stores the pending cookies, unless
+// the file was replaced since they were read.
+// it must be called with fileMu held
+func (jar *CookieJar) writeCookies() {
+	cookies := jar.pendingCookies
+	if cookies == nil {
+		return
+	}
+	jar.pendingCookies = nil
+
+	info, err := os.Stat(filepath.Join(CookiesDir, jar.fileName))
+	if err != nil || !info.ModTime().Equal(jar.pendingModTime) {
+		slog.Warn("cookie jar changed, discarding unsaved cookies", "file", jar.fileName)
+		return
+	}
+	cookieJarsMu.Lock()
+	quarantined := time.Now().Before(jar.quarantinedUntil)
+	cookieJarsMu.Unlock()
+	if quarantined {
+		// writing the file would lift the quarantine
+		return
+	}
+	jar.lastFlush = time.Now()
+	err = storeCookieFile(jar.fileName, cookies)
+	if err != nil {
+		slog.Error("failed to save cookie jar", "file", jar.fileName, "error", err)
+	}
+}
+
+// FlushCookieJars writes the cookies not yet
+// saved by the jars, e.g. on shutdown
+func FlushCookieJars() {
+	cookieJarsMu.Lock()
+	var jars []*CookieJar
+	for _, pool := range cookieJarPools {
+		for _, jar := range pool.jars {
+			jars = append(jars, jar)
+		}
+	}
+	cookieJarsMu.Unlock()
+
+	for _, jar := range jars {
+		jar.flush()
+	}
+}
+
+func listCookieFiles(platform string) []string {
+	var fileNames []string
+	legacyFile := platform + ".txt"
+	if _, err := os.Stat(filepath.Join(CookiesDir, legacyFile)); err == nil {
+		fileNames = append(fileNames, legacyFile)
+	}
+	paths, _ := filepath.Glob(filepath.Join(CookiesDir, platform, "*.txt"))
+	for _, path := range paths {
+		fileNames = append(fileNames, filepath.Join(platform, filepath.Base(path)))
+	}
+	return fileNames
+}
+
+func isLoginWall(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+	if resp.Request == nil {
+		return false
+	}
+	// redirects to login pages
+	for _, segment := range strings.Split(resp.Request.URL.Path, "/") {
+		if strings.EqualFold(segment, "login") {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeCookies updates the cookies with the ones set
+// by a response, removing the expired ones
+func mergeCookies(
+	cookies []*http.Cookie,
+	setCookies []*http.Cookie,
+	host string,
+) ([]*http.Cookie, bool) {
+	merged := slices.Clone(cookies)
+	changed := false
+	now := time.Now()
+
+	for _, setCookie := range setCookies {
+		domain := setCookie.Domain
+		switch {
+		case domain == "":
+			domain = host
+		case !strings.HasPrefix(domain, "."):
+			// the domain attribute includes subdomains
+			domain = "." + domain
+		}
+		path := setCookie.Path
+		if path == "" {
+			path = "/"
+		}
+		expires := setCookie.Expires
+		if setCookie.MaxAge > 0 {
+			expires = now.Add(time.Duration(setCookie.MaxAge) * time.Second)
+		}
+		expired := setCookie.MaxAge < 0 ||
+			(!expires.IsZero() && expires.Before(now))
+
+		idx := slices.IndexFunc(merged, func(cookie *http.Cookie) bool {
+			return cookie.Name == setCookie.Name &&
+				strings.TrimPrefix(cookie.Domain, ".") == strings.TrimPrefix(domain, ".") &&
+				(cookie.Path == path || cookie.Path == "")
+		})
+		switch {
+		case expired && idx >= 0:
+			merged = slices.Delete(merged, idx, idx+1)
+			changed = true
+		case expired:
+		case idx >= 0:
+			if merged[idx].Value == setCookie.Value {
+				continue
+			}
+			// cookies are shared with the cache, so copy them
+			updated := *merged[idx]
+			updated.Value = setCookie.Value
+			if !expires.IsZero() {
+				updated.Expires = expires
+			}
+			merged[idx] = &updated
+			changed = true
+		default:
+			merged = append(merged, &http.Cookie{
+				Name:     setCookie.Name,
+				Value:    setCookie.Value,
+				Domain:   domain,
+				Path:     path,
+				Expires:  expires,
+				Secure:   setCookie.Secure,
+				HttpOnly: setCookie.HttpOnly,
+			})
+			changed = true
+		}
+	}
+	return merged, changed
+}
@@ -0,0 +1,166 @@
+package util
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aki237/nscjar"
+)
+
+const CookiesDir = "cookies"
+
+type cachedCookieFile struct {
+	cookies []*http.Cookie
+	modTime time.Time
+}
+
+var (
+	cookiesCache = make(map[string]*cachedCookieFile)
+	cookiesMu    sync.Mutex
+)
+
+// ParseCookieFile returns the cookies of a file in the
+// cookies directory. files are parsed again when they
+// change, keeping the last valid cookies if parsing fails
+func ParseCookieFile(fileName string) ([]*http.Cookie, error) {
+	cookiePath := filepath.Join(CookiesDir, fileName)
+	info, statErr := os.Stat(cookiePath)
+
+	cookiesMu.Lock()
+	defer cookiesMu.Unlock()
+
+	cached, ok := cookiesCache[fileName]
+	if statErr != nil {
+		delete(cookiesCache, fileName)
+		return nil, fmt.Errorf("failed to open cookie file: %w", statErr)
+	}
+	if ok && info.ModTime().Equal(cached.modTime) {
+		return cached.cookies, nil
+	}
+
+	cookies, err := readCookieFile(cookiePath)
+	if err != nil {
+		if ok {
+			slog.Warn(
+				"failed to reload cookie file, keeping previous cookies",
+				"file", fileName,
+				"error", err,
+			)
+			// don't retry until the file changes again
+			cached.modTime = info.ModTime()
+			return cached.cookies, nil
+		}
+		return nil, err
+	}
+	if ok {
+		slog.Info("reloaded cookie file", "file", fileName, "cookies", len(cookies))
+	}
+	cookiesCache[fileName] = &cachedCookieFile{
+		cookies: cookies,
+		modTime: info.ModTime(),
+	}
+	return cookies, nil
+}
+
+// ReloadCookieFiles parses again the
+// cached cookie files that changed
+func ReloadCookieFiles() {
+	cookiesMu.Lock()
+	fileNames := make([]string, 0, len(cookiesCache))
+	for fileName := range cookiesCache {
+		fileNames = append(fileNames, fileName)
+	}
+	cookiesMu.Unlock()
+
+	for _, fileName := range fileNames {
+		_, err := ParseCookieFile(fileName)
+		if err != nil {
+			slog.Warn("cookie file is no longer available", "file", fileName, "error", err)
+		}
+	}
+}
+
+func readCookieFile(cookiePath string) ([]*http.Cookie, error) {
+	cookieFile, err := os.Open(cookiePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cookie file: %w", err)
+	}
+	defer cookieFile.Close()
+
+	var parser nscjar.Parser
+	cookies, err := parser.Unmarshal(cookieFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cookie file: %w", err)
+	}
+	return cookies, nil
+}
+
+// storeCookieFile writes the cookies to a file
+// in the cookies directory, in netscape format
+func storeCookieFile(fileName string, cookies []*http.Cookie) error {
+	cookiePath := filepath.Join(CookiesDir, fileName)
+
+	var builder strings.Builder
+	builder.WriteString("# Netscape HTTP Cookie File\n\n")
+	for _, cookie := range cookies {
+		var expires int64
+		if !cookie.Expires.IsZero() {
+			expires = cookie.Expires.Unix()
+		}
+		path := cookie.Path
+		if path == "" {
+			path = "/"
+		}
+		if cookie.HttpOnly {
+			// netscape files mark httponly cookies with a prefix
+			builder.WriteString("#HttpOnly_")
+		}
+		fmt.Fprintf(
+			&builder,
+			"%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			cookie.Domain,
+			netscapeBool(strings.HasPrefix(cookie.Domain, ".")),
+			path,
+			netscapeBool(cookie.Secure),
+			expires,
+			cookie.Name,
+			cookie.Value,
+		)
+	}
+	// write to a temporary file first, so a crash
+	// never leaves a truncated cookie file behind
+	tempPath := cookiePath + ".tmp"
+	err := os.WriteFile(tempPath, []byte(builder.String()), 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write cookie file: %w", err)
+	}
+	err = os.Rename(tempPath, cookiePath)
+	if err != nil {
+		return fmt.Errorf("failed to write cookie file: %w", err)
+	}
+	info, err := os.Stat(cookiePath)
+	if err != nil {
+		return fmt.Errorf("failed to write cookie file: %w", err)
+	}
+
+	cookiesMu.Lock()
+	defer cookiesMu.Unlock()
+	cookiesCache[fileName] = &cachedCookieFile{
+		cookies: cookies,
+		modTime: info.ModTime(),
+	}
+	return nil
+}
+
+func netscapeBool(value bool) string {
+	if value {
+		return "TRUE"
+	}
+	return "FALSE"
+}
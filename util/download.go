@@ -3,6 +3,7 @@ package util
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -10,17 +11,27 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"govd/models"
 	"govd/util/av"
+	"govd/util/metrics"
 
 	"github.com/google/uuid"
 )
 
-var downloadHTTPSession = GetDefaultHTTPClient()
+// downloads use per-request timeouts, a client
+// timeout would stop long streaming downloads
+var downloadHTTPSession = &http.Client{
+	Transport: GetBaseTransport(),
+}
+
+// report streaming downloads progress every 1MB
+const streamProgressInterval = 1024 * 1024
 
 func DefaultConfig() *models.DownloadConfig {
 	downloadsDir := os.Getenv("DOWNLOADS_DIR")
@@ -60,11 +71,32 @@ func DownloadFile(
 			}
 
 			filePath := filepath.Join(config.DownloadDir, fileName)
-			err := runChunkedDownload(ctx, fileURL, filePath, config)
+			downloadPath := filePath
+			if config.ResumeKey != "" {
+				// the file name can change between runs,
+				// so partial data is kept under the key
+				downloadPath = filepath.Join(
+					config.DownloadDir,
+					config.ResumeKey+partFileExt,
+				)
+			}
+			err := runChunkedDownload(ctx, fileURL, downloadPath, config)
 			if err != nil {
+				GetLogger(ctx).Warn(
+					"failed to download url",
+					"url", fileURL,
+					"error", err,
+				)
 				errs = append(errs, err)
 				continue
 			}
+			if downloadPath != filePath {
+				err := os.Rename(downloadPath, filePath)
+				if err != nil {
+					os.Remove(downloadPath)
+					return "", fmt.Errorf("failed to rename file: %w", err)
+				}
+			}
 
 			if config.Remux {
 				err := av.RemuxFile(filePath)
@@ -105,7 +137,7 @@ func DownloadFileWithSegments(
 		os.RemoveAll(tempDir)
 		return "", fmt.Errorf("failed to download segments: %w", err)
 	}
-	mergedFilePath, err := av.MergeSegments(downloadedFiles, fileName)
+	mergedFilePath, err := av.MergeSegments(ctx, downloadedFiles, fileName)
 	if err != nil {
 		os.RemoveAll(tempDir)
 		return "", fmt.Errorf("failed to merge segments: %w", err)
@@ -164,12 +196,12 @@ func downloadInMemory(
 		// continue with the request
 	}
 
-	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, fileURL, nil)
+	req, err := newDownloadRequest(reqCtx, fileURL, config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	resp, err := downloadHTTPSession.Do(req)
+	resp, err := getDownloadClient(config).Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download file: %w", err)
 	}
@@ -202,6 +234,7 @@ func downloadInMemory(
 		n, err := limitedReader.Read(buf)
 		if n > 0 {
 			data = append(data, buf[:n]...)
+			metrics.DownloadedBytes.Add(float64(n))
 		}
 		if err == io.EOF {
 			break
@@ -242,12 +275,35 @@ func runChunkedDownload(
 		config.Concurrency = optimalConcurrency
 	}
 
-	fileSize, err := getFileSize(ctx, fileURL, config.Timeout)
+	fileSize, rangeSupported, err := probeFile(ctx, fileURL, config)
 	if err != nil {
 		return err
 	}
+	if !rangeSupported || fileSize <= 0 {
+		// chunks need both range requests and a known size
+		return runStreamingDownload(ctx, fileURL, filePath, fileSize, config)
+	}
 
-	file, err := os.Create(filePath)
+	numChunks := 1
+	if fileSize > 0 {
+		numChunks = int(math.Ceil(float64(fileSize) / float64(config.ChunkSize)))
+	}
+
+	// chunks can only be tracked if the size is known
+	var state *downloadState
+	if config.ResumeKey != "" && fileSize > 0 {
+		state = loadDownloadState(
+			filePath, fileSize,
+			config.ChunkSize, numChunks,
+		)
+	}
+
+	var file *os.File
+	if state != nil && state.isResumed() {
+		file, err = os.OpenFile(filePath, os.O_RDWR, 0644)
+	} else {
+		file, err = os.Create(filePath)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
@@ -260,11 +316,6 @@ func runChunkedDownload(
 		}
 	}
 
-	numChunks := 1
-	if fileSize > 0 {
-		numChunks = int(math.Ceil(float64(fileSize) / float64(config.ChunkSize)))
-	}
-
 	semaphore := make(chan struct{}, config.Concurrency)
 	var wg sync.WaitGroup
 
@@ -282,6 +333,12 @@ func runChunkedDownload(
 	var fileMutex sync.Mutex
 
 	for i := range numChunks {
+		if state != nil && state.isCompleted(i) {
+			chunkSize := min(config.ChunkSize, fileSize-i*config.ChunkSize)
+			completedChunks.Add(1)
+			completedBytes.Add(int64(chunkSize))
+			continue
+		}
 		wg.Add(1)
 
 		go func(chunkIndex int) {
@@ -316,14 +373,21 @@ func runChunkedDownload(
 				return
 			}
 
+			if state != nil {
+				// a failed save only means the chunk
+				// is downloaded again when resuming
+				state.markCompleted(chunkIndex)
+			}
+
 			// update progress
 			chunkSize := end - start + 1
 			completedChunks.Add(1)
 			completedBytes.Add(int64(chunkSize))
 			if fileSize > 0 {
-				progress := float64(completedBytes.Load()) / float64(fileSize)
+				downloaded := completedBytes.Load()
+				progress := float64(downloaded) / float64(fileSize)
 				if config.ProgressUpdater != nil {
-					config.ProgressUpdater(progress)
+					config.ProgressUpdater(progress, downloaded)
 				}
 			}
 		}(i)
@@ -354,44 +418,199 @@ func runChunkedDownload(
 	case <-ctx.Done():
 		cancelDownload()
 		<-done // wait for all goroutines to finish
-		os.Remove(filePath)
+		if state == nil || !keepPartialDownload(ctx, ctx.Err()) {
+			os.Remove(filePath)
+			removeDownloadState(filePath)
+		}
 		return ctx.Err()
 	case <-done:
 		// no errors
 	}
 
 	if len(multiErr) > 0 {
-		os.Remove(filePath)
-		return fmt.Errorf("multiple download errors: %v", multiErr)
+		err := errors.Join(multiErr...)
+		if state == nil || !keepPartialDownload(ctx, err) {
+			os.Remove(filePath)
+			removeDownloadState(filePath)
+		}
+		return fmt.Errorf("multiple download errors: %w", err)
 	}
 
+	removeDownloadState(filePath)
 	return nil
 }
 
-func getFileSize(
+// probeFile requests the first byte of the file, instead
+// of using HEAD which many CDNs reject. it returns the file
+// size (-1 if unknown) and whether range requests are supported
+func probeFile(
 	ctx context.Context,
 	fileURL string,
-	timeout time.Duration,
-) (int, error) {
-	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	config *models.DownloadConfig,
+) (int, bool, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, config.Timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(reqCtx, http.MethodHead, fileURL, nil)
+	req, err := newDownloadRequest(reqCtx, fileURL, config)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %w", err)
+		return 0, false, err
 	}
+	req.Header.Set("Range", "bytes=0-0")
 
-	resp, err := downloadHTTPSession.Do(req)
+	resp, err := getDownloadClient(config).Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get file size: %w", err)
+		return 0, false, fmt.Errorf("failed to get file info: %w", err)
+	}
+	// the body is not read, a server ignoring
+	// the range would send the whole file
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		size := parseContentRangeSize(resp.Header.Get("Content-Range"))
+		return size, size > 0, nil
+	case http.StatusOK:
+		// range is not supported
+		return int(resp.ContentLength), false, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// empty files can't satisfy any range
+		return -1, false, nil
+	default:
+		return 0, false, fmt.Errorf("failed to get file info: status code %d", resp.StatusCode)
+	}
+}
+
+// parseContentRangeSize returns the complete length from
+// a header like "bytes 0-0/1234", or -1 if it's unknown
+func parseContentRangeSize(contentRange string) int {
+	_, size, ok := strings.Cut(contentRange, "/")
+	if !ok || size == "*" {
+		return -1
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(size))
+	if err != nil || value < 0 {
+		return -1
+	}
+	return value
+}
+
+// runStreamingDownload downloads the file with a single
+// request, for servers not supporting range requests or
+// not reporting the file size. fileSize is only used to
+// report progress and can be -1
+func runStreamingDownload(
+	ctx context.Context,
+	fileURL string,
+	filePath string,
+	fileSize int,
+	config *models.DownloadConfig,
+) error {
+	// without range requests partial data can't
+	// be resumed, so it's always removed
+	var lastErr error
+	for attempt := 0; attempt <= config.RetryAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				os.Remove(filePath)
+				return ctx.Err()
+			case <-time.After(config.RetryDelay):
+			}
+			metrics.ChunkRetries.Inc()
+			GetLogger(ctx).Debug(
+				"retrying stream download",
+				"attempt", attempt,
+				"error", lastErr,
+			)
+		}
+		err := streamToFile(ctx, fileURL, filePath, fileSize, config)
+		if err == nil {
+			return nil
+		}
+		os.Remove(filePath)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		lastErr = err
+	}
+	return fmt.Errorf("all %d attempts failed: %w", config.RetryAttempts+1, lastErr)
+}
+
+func streamToFile(
+	ctx context.Context,
+	fileURL string,
+	filePath string,
+	fileSize int,
+	config *models.DownloadConfig,
+) error {
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the timeout applies while waiting for data,
+	// since the whole file can take much longer
+	idleTimer := time.AfterFunc(config.Timeout, cancel)
+	defer idleTimer.Stop()
+
+	req, err := newDownloadRequest(reqCtx, fileURL, config)
+	if err != nil {
+		return err
+	}
+	resp, err := getDownloadClient(config).Do(req)
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("failed to get file info: status code %d", resp.StatusCode)
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if fileSize <= 0 && resp.ContentLength > 0 {
+		fileSize = int(resp.ContentLength)
 	}
 
-	return int(resp.ContentLength), nil
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	var written int64
+	var lastReported int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			idleTimer.Reset(config.Timeout)
+			if _, err := file.Write(buf[:n]); err != nil {
+				return fmt.Errorf("failed to write file: %w", err)
+			}
+			written += int64(n)
+			metrics.DownloadedBytes.Add(float64(n))
+			if config.ProgressUpdater != nil && written-lastReported >= streamProgressInterval {
+				lastReported = written
+				config.ProgressUpdater(streamProgress(written, fileSize), written)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to read response body: %w", readErr)
+		}
+	}
+	if config.ProgressUpdater != nil {
+		config.ProgressUpdater(1, written)
+	}
+	return nil
+}
+
+// streamProgress returns the download progress,
+// or -1 if the file size is unknown
+func streamProgress(written int64, fileSize int) float64 {
+	if fileSize <= 0 {
+		return -1
+	}
+	return float64(written) / float64(fileSize)
 }
 
 func downloadChunkToFile(
@@ -413,16 +632,27 @@ func downloadChunkToFile(
 				return ctx.Err()
 			case <-time.After(config.RetryDelay):
 			}
+			metrics.ChunkRetries.Inc()
+			GetLogger(ctx).Debug(
+				"retrying chunk download",
+				"start", start,
+				"end", end,
+				"attempt", attempt,
+				"error", lastErr,
+			)
 		}
 
 		err := downloadAndWriteChunk(
 			ctx, fileURL, file,
-			start, end, config.Timeout,
+			start, end, config,
 			fileMutex,
 		)
 		if err == nil {
 			return nil
 		}
+		if errors.Is(err, errPermanentDownload) {
+			return err
+		}
 
 		lastErr = err
 	}
@@ -436,27 +666,29 @@ func downloadAndWriteChunk(
 	file *os.File,
 	start int,
 	end int,
-	timeout time.Duration,
+	config *models.DownloadConfig,
 	fileMutex *sync.Mutex,
 ) error {
-	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	reqCtx, cancel := context.WithTimeout(ctx, config.Timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, fileURL, nil)
+	req, err := newDownloadRequest(reqCtx, fileURL, config)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
 	}
 
 	req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", start, end))
 
-	resp, err := downloadHTTPSession.Do(req)
+	resp, err := getDownloadClient(config).Do(req)
 	if err != nil {
 		return fmt.Errorf("download failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	// a full response would be written at the chunk offset
+	err = checkDownloadStatus(resp.StatusCode, http.StatusPartialContent)
+	if err != nil {
+		return err
 	}
 
 	// use a fixed-size buffer for
@@ -470,7 +702,8 @@ func downloadAndWriteChunk(
 		return fmt.Errorf("failed to seek file: %w", err)
 	}
 
-	_, err = io.CopyBuffer(file, resp.Body, buf)
+	written, err := io.CopyBuffer(file, resp.Body, buf)
+	metrics.DownloadedBytes.Add(float64(written))
 	if err != nil {
 		return fmt.Errorf("failed to write chunk data: %w", err)
 	}
@@ -482,16 +715,16 @@ func downloadFile(
 	ctx context.Context,
 	fileURL string,
 	filePath string,
-	timeout time.Duration,
+	config *models.DownloadConfig,
 ) (string, error) {
-	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	reqCtx, cancel := context.WithTimeout(ctx, config.Timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, fileURL, nil)
+	req, err := newDownloadRequest(reqCtx, fileURL, config)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", err
 	}
-	resp, err := downloadHTTPSession.Do(req)
+	resp, err := getDownloadClient(config).Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to download file: %w", err)
 	}
@@ -510,7 +743,8 @@ func downloadFile(
 	// use a fixed-size buffer for
 	// copying to avoid large allocations (32KB)
 	buf := make([]byte, 32*1024)
-	_, err = io.CopyBuffer(file, resp.Body, buf)
+	written, err := io.CopyBuffer(file, resp.Body, buf)
+	metrics.DownloadedBytes.Add(float64(written))
 	if err != nil {
 		return "", fmt.Errorf("failed to write file: %w", err)
 	}
@@ -533,6 +767,9 @@ func downloadSegments(
 
 	var firstErr atomic.Value
 
+	var completedSegments atomic.Int64
+	var completedBytes atomic.Int64
+
 	downloadedFiles := make([]string, len(segmentURLs))
 	defer func() {
 		if firstErr.Load() != nil {
@@ -560,26 +797,45 @@ func downloadSegments(
 			}
 
 			// acquire semaphore slot
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-downloadCtx.Done():
+				return
+			}
 
 			segmentFileName := fmt.Sprintf("segment_%05d", idx)
 			segmentPath := filepath.Join(path, segmentFileName)
 
 			filePath, err := downloadFile(
-				ctx, url, segmentPath,
-				config.Timeout,
+				downloadCtx, url, segmentPath,
+				config,
 			)
 
 			if err != nil {
 				if firstErr.Load() == nil {
 					firstErr.Store(fmt.Errorf("failed to download segment %d: %w", idx, err))
 					cancelDownload()
+					GetLogger(ctx).Warn(
+						"failed to download segment",
+						"segment", idx,
+						"error", err,
+					)
 				}
 				return
 			}
 
 			downloadedFiles[idx] = filePath
+			metrics.DownloadedSegments.Inc()
+
+			// update progress
+			if config.ProgressUpdater != nil {
+				if info, err := os.Stat(filePath); err == nil {
+					completedBytes.Add(info.Size())
+				}
+				progress := float64(completedSegments.Add(1)) / float64(len(segmentURLs))
+				config.ProgressUpdater(progress, completedBytes.Load())
+			}
 		}(i, segmentURL)
 	}
 	wg.Wait()
@@ -599,3 +855,30 @@ func downloadSegments(
 
 	return downloadedFiles, nil
 }
+
+// newDownloadRequest returns a GET request with the
+// headers and cookies required by the media server
+func newDownloadRequest(
+	ctx context.Context,
+	fileURL string,
+	config *models.DownloadConfig,
+) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range config.Headers {
+		req.Header.Set(key, value)
+	}
+	for _, cookie := range config.Cookies {
+		req.AddCookie(cookie)
+	}
+	return req, nil
+}
+
+func getDownloadClient(config *models.DownloadConfig) models.HTTPClient {
+	if config.HTTPClient != nil {
+		return config.HTTPClient
+	}
+	return downloadHTTPSession
+}
@@ -0,0 +1,155 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// ResumableDownloadTTL is how long partial downloads
+// are kept on disk to be resumed
+const ResumableDownloadTTL = 24 * time.Hour
+
+const (
+	partFileExt  = ".part"
+	stateFileExt = ".state"
+)
+
+// ErrDownloadCanceled is the cause of downloads canceled
+// by the user, whose partial data is never resumed
+var ErrDownloadCanceled = errors.New("download canceled")
+
+// errPermanentDownload marks failures that
+// would happen again when resuming
+var errPermanentDownload = errors.New("permanent download failure")
+
+// downloadState tracks the completed chunks of a
+// download in a sidecar file, so it can be resumed
+// after a restart
+type downloadState struct {
+	FileSize  int    `json:"file_size"`
+	ChunkSize int    `json:"chunk_size"`
+	Completed []bool `json:"completed"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// loadDownloadState returns the state of a previous
+// download of the file, or a new state if there is
+// none or it doesn't match the current download
+func loadDownloadState(
+	filePath string,
+	fileSize int,
+	chunkSize int,
+	numChunks int,
+) *downloadState {
+	statePath := filePath + stateFileExt
+	state := &downloadState{
+		FileSize:  fileSize,
+		ChunkSize: chunkSize,
+		Completed: make([]bool, numChunks),
+		path:      statePath,
+	}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return state
+	}
+	var stored downloadState
+	err = sonic.ConfigFastest.Unmarshal(data, &stored)
+	if err != nil ||
+		stored.FileSize != fileSize ||
+		stored.ChunkSize != chunkSize ||
+		len(stored.Completed) != numChunks {
+		return state
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		return state
+	}
+	state.Completed = stored.Completed
+	return state
+}
+
+// isResumed reports whether any chunk
+// was completed by a previous download
+func (state *downloadState) isResumed() bool {
+	for _, completed := range state.Completed {
+		if completed {
+			return true
+		}
+	}
+	return false
+}
+
+func (state *downloadState) isCompleted(idx int) bool {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.Completed[idx]
+}
+
+func (state *downloadState) markCompleted(idx int) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.Completed[idx] = true
+	return state.save()
+}
+
+func (state *downloadState) save() error {
+	data, err := sonic.ConfigFastest.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode download state: %w", err)
+	}
+	// write to a temporary file first, so a crash
+	// never leaves a truncated state behind
+	tempPath := state.path + ".tmp"
+	err = os.WriteFile(tempPath, data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write download state: %w", err)
+	}
+	err = os.Rename(tempPath, state.path)
+	if err != nil {
+		return fmt.Errorf("failed to write download state: %w", err)
+	}
+	return nil
+}
+
+func removeDownloadState(filePath string) {
+	os.Remove(filePath + stateFileExt)
+}
+
+// keepPartialDownload reports whether the partial data of a
+// download stopped by err can be resumed later. leftovers of
+// jobs that are never resumed are removed by their TTL
+func keepPartialDownload(ctx context.Context, err error) bool {
+	if errors.Is(context.Cause(ctx), ErrDownloadCanceled) {
+		return false
+	}
+	return !errors.Is(err, errPermanentDownload)
+}
+
+// checkDownloadStatus returns an error if the response status
+// is not the expected one. client errors are permanent, except
+// for timeouts and rate limits
+func checkDownloadStatus(statusCode int, expected int) error {
+	if statusCode == expected {
+		return nil
+	}
+	if statusCode >= 400 && statusCode < 500 &&
+		statusCode != http.StatusRequestTimeout &&
+		statusCode != http.StatusTooManyRequests {
+		return fmt.Errorf("%w: unexpected status code: %d", errPermanentDownload, statusCode)
+	}
+	return fmt.Errorf("unexpected status code: %d", statusCode)
+}
+
+func isResumableFile(path string) bool {
+	return strings.HasSuffix(path, partFileExt) ||
+		strings.HasSuffix(path, partFileExt+stateFileExt)
+}
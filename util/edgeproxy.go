@@ -30,6 +30,11 @@ func NewEdgeProxyFromConfig(cfg *models.ExtractorConfig) *EdgeProxyClient {
 			Timeout:   60 * time.Second,
 		}
 	}
+	// the edge proxy itself can be reached through a proxy
+	transport, ok := baseClient.Transport.(*http.Transport)
+	if ok && (cfg.HTTPProxy != "" || cfg.HTTPSProxy != "") {
+		configureProxyTransport(transport, cfg)
+	}
 	return &EdgeProxyClient{
 		client:   baseClient,
 		proxyURL: cfg.EdgeProxyURL,
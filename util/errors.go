@@ -1,6 +1,14 @@
 package util
 
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
 type Error struct {
+	// Code identifies the error in logs and translations
+	Code    string
 	Message string
 }
 
@@ -9,15 +17,133 @@ func (err *Error) Error() string {
 }
 
 var (
-	ErrUnavailable              = &Error{Message: "this content is unavailable"}
-	ErrNotImplemented           = &Error{Message: "this feature is not implemented"}
-	ErrTimeout                  = &Error{Message: "timeout error when downloading. try again"}
-	ErrUnknownRIFF              = &Error{Message: "uknown RIFF format"}
-	ErrUnsupportedImageFormat   = &Error{Message: "unsupported image format"}
-	ErrFileTooShort             = &Error{Message: "file too short"}
-	ErrDownloadFailed           = &Error{Message: "download failed"}
-	ErrUnsupportedExtractorType = &Error{Message: "unsupported extractor type"}
-	ErrMediaGroupLimitExceeded  = &Error{Message: "media group limit exceeded for this group. try changing /settings"}
-	ErrNSFWNotAllowed           = &Error{Message: "this content is marked as nsfw and can't be downloaded in this group. try changing /settings or use me privately"}
-	ErrInlineMediaGroup         = &Error{Message: "you can't download media groups in inline mode. try using me in a private chat"}
+	ErrUnavailable              = &Error{Code: "unavailable", Message: "this content is unavailable"}
+	ErrNotImplemented           = &Error{Code: "not_implemented", Message: "this feature is not implemented"}
+	ErrTimeout                  = &Error{Code: "timeout", Message: "timeout error when downloading. try again"}
+	ErrUnknownRIFF              = &Error{Code: "unknown_riff", Message: "uknown RIFF format"}
+	ErrUnsupportedImageFormat   = &Error{Code: "unsupported_image_format", Message: "unsupported image format"}
+	ErrFileTooShort             = &Error{Code: "file_too_short", Message: "file too short"}
+	ErrDownloadFailed           = &Error{Code: "download_failed", Message: "download failed"}
+	ErrUnsupportedExtractorType = &Error{Code: "unsupported_extractor_type", Message: "unsupported extractor type"}
+	ErrMediaGroupLimitExceeded  = &Error{Code: "media_group_limit_exceeded", Message: "media group limit exceeded for this group. try changing /settings"}
+	ErrNSFWNotAllowed           = &Error{Code: "nsfw_not_allowed", Message: "this content is marked as nsfw and can't be downloaded in this group. try changing /settings or use me privately"}
+	ErrInlineMediaGroup         = &Error{Code: "inline_media_group", Message: "you can't download media groups in inline mode. try using me in a private chat"}
+	ErrFormatsMediaGroup        = &Error{Code: "formats_media_group", Message: "format selection is not available for media groups"}
+	ErrFormatsTaskExpired       = &Error{Code: "formats_task_expired", Message: "this format list has expired. send the link again"}
+	ErrEmptyPlaylist            = &Error{Code: "empty_playlist", Message: "no downloadable items found in this playlist"}
+	ErrPlaylistFailed           = &Error{Code: "playlist_failed", Message: "failed to download any item of this playlist"}
+	ErrQueueFull                = &Error{Code: "queue_full", Message: "the bot is busy right now. try again later"}
+	ErrFileTooLarge             = &Error{Code: "file_too_large", Message: "this file is too large to be uploaded"}
+	ErrShuttingDown             = &Error{Code: "shutting_down", Message: "the bot is restarting. try again in a moment"}
+	ErrJobInterrupted           = &Error{Code: "job_interrupted", Message: "the bot is restarting. your download will resume shortly"}
 )
+
+// kinds of extractor failures. extractors wrap the
+// cause with NewExtractorError, so errors.Is matches them
+var (
+	ErrContentPrivate      = &Error{Code: "content_private", Message: "this content is private"}
+	ErrContentDeleted      = &Error{Code: "content_deleted", Message: "this content has been deleted"}
+	ErrGeoBlocked          = &Error{Code: "geo_blocked", Message: "this content is not available in the bot's country"}
+	ErrLoginRequired       = &Error{Code: "login_required", Message: "this content requires an account, and the bot can't access it right now"}
+	ErrPlatformRateLimited = &Error{Code: "platform_rate_limited", Message: "the platform is limiting the bot's requests. try again later"}
+	ErrAgeRestricted       = &Error{Code: "age_restricted", Message: "this content is age restricted"}
+	ErrUnsupportedPostType = &Error{Code: "unsupported_post_type", Message: "this type of post is not supported"}
+	ErrUpstreamChanged     = &Error{Code: "upstream_changed", Message: "the platform changed and the bot can't read this content yet. try again later"}
+)
+
+// kinds of rate limits, used by RateLimitError
+var (
+	ErrChatRateLimited  = &Error{Code: "chat_rate_limited", Message: "too many requests in this group"}
+	ErrUserRateLimited  = &Error{Code: "user_rate_limited", Message: "you are sending too many requests"}
+	ErrChatQuotaReached = &Error{Code: "chat_quota_reached", Message: "daily download limit reached for this group"}
+	ErrUserQuotaReached = &Error{Code: "user_quota_reached", Message: "you reached your daily download quota"}
+)
+
+// ExtractorError is an extractor failure classified
+// by its kind, keeping the underlying cause for logs
+type ExtractorError struct {
+	Kind  *Error
+	Cause error
+}
+
+// NewExtractorError classifies the cause with the kind.
+// a nil kind returns the cause unchanged
+func NewExtractorError(kind *Error, cause error) error {
+	if kind == nil {
+		return cause
+	}
+	return &ExtractorError{
+		Kind:  kind,
+		Cause: cause,
+	}
+}
+
+func (err *ExtractorError) Error() string {
+	if err.Cause == nil {
+		return err.Kind.Message
+	}
+	return err.Kind.Message + ": " + err.Cause.Error()
+}
+
+func (err *ExtractorError) Unwrap() []error {
+	if err.Cause == nil {
+		return []error{err.Kind}
+	}
+	return []error{err.Kind, err.Cause}
+}
+
+// ErrorFromStatus returns the kind of failure
+// of a platform response status, if known
+func ErrorFromStatus(statusCode int) *Error {
+	switch statusCode {
+	case http.StatusUnauthorized:
+		return ErrLoginRequired
+	case http.StatusNotFound, http.StatusGone:
+		return ErrContentDeleted
+	case http.StatusTooManyRequests:
+		return ErrPlatformRateLimited
+	case http.StatusUnavailableForLegalReasons:
+		return ErrGeoBlocked
+	default:
+		return nil
+	}
+}
+
+// RateLimitError is returned when a user or chat
+// exceeds its request rate or daily quota
+type RateLimitError struct {
+	Kind       *Error
+	RetryAfter time.Duration
+}
+
+func (err *RateLimitError) Error() string {
+	return fmt.Sprintf(
+		"%s. try again in %s",
+		err.Kind.Message,
+		FormatRetryAfter(err.RetryAfter),
+	)
+}
+
+func (err *RateLimitError) Unwrap() error {
+	return err.Kind
+}
+
+// FormatRetryAfter returns the duration
+// rounded to seconds, e.g. "1h 5m"
+func FormatRetryAfter(duration time.Duration) string {
+	if duration < time.Second {
+		duration = time.Second
+	}
+	duration = duration.Round(time.Second)
+	hours := int(duration.Hours())
+	minutes := int(duration.Minutes()) % 60
+	seconds := int(duration.Seconds()) % 60
+	switch {
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm %ds", minutes, seconds)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
@@ -0,0 +1,379 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"govd/models"
+
+	"github.com/bytedance/sonic"
+)
+
+type FixtureMode string
+
+const (
+	FixtureModeOff    FixtureMode = ""
+	FixtureModeRecord FixtureMode = "record"
+	FixtureModeReplay FixtureMode = "replay"
+
+	defaultFixturesDir = "fixtures"
+	redactedValue      = "redacted"
+)
+
+// headers never written to fixtures
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Csrf-Token",
+}
+
+var (
+	fixtureMode     FixtureMode
+	fixtureModeOnce sync.Once
+	// serializes writes to fixture files
+	fixturesMu sync.Mutex
+)
+
+// HTTPFixture is a request/response pair
+// recorded from an extractor client
+type HTTPFixture struct {
+	Request  *FixtureRequest  `json:"request"`
+	Response *FixtureResponse `json:"response"`
+}
+
+type FixtureRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type FixtureResponse struct {
+	StatusCode int `json:"status_code"`
+	// the url after redirects
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+	// binary bodies are base64 encoded
+	BodyBase64 bool `json:"body_base64,omitempty"`
+}
+
+// fixtureClient records or replays the
+// requests of an extractor client
+type fixtureClient struct {
+	extractor string
+	client    models.HTTPClient
+	mode      FixtureMode
+}
+
+// GetFixtureMode returns the mode set with SetFixtureMode,
+// or the one from HTTP_FIXTURES_MODE env (record, replay)
+func GetFixtureMode() FixtureMode {
+	fixtureModeOnce.Do(func() {
+		if fixtureMode == FixtureModeOff {
+			fixtureMode = FixtureMode(strings.ToLower(os.Getenv("HTTP_FIXTURES_MODE")))
+		}
+	})
+	return fixtureMode
+}
+
+// SetFixtureMode overrides HTTP_FIXTURES_MODE env.
+// it must be called before any client is used
+func SetFixtureMode(mode FixtureMode) {
+	fixtureModeOnce.Do(func() {})
+	fixtureMode = mode
+}
+
+// GetFixturesDir returns the directory of fixtures,
+// from HTTP_FIXTURES_DIR env
+func GetFixturesDir() string {
+	if dir := os.Getenv("HTTP_FIXTURES_DIR"); dir != "" {
+		return dir
+	}
+	return defaultFixturesDir
+}
+
+// WithFixtures wraps the client of the extractor to
+// record or replay its requests, depending on the mode
+func WithFixtures(
+	extractor string,
+	client models.HTTPClient,
+) models.HTTPClient {
+	mode := GetFixtureMode()
+	switch mode {
+	case FixtureModeRecord, FixtureModeReplay:
+		return &fixtureClient{
+			extractor: extractor,
+			client:    client,
+			mode:      mode,
+		}
+	default:
+		return client
+	}
+}
+
+func (c *fixtureClient) Do(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	fixturePath := filepath.Join(
+		GetFixturesDir(),
+		c.extractor,
+		"http",
+		fixtureKey(req.Method, req.URL.String(), body)+".json",
+	)
+	if c.mode == FixtureModeReplay {
+		return c.replayFixture(req, fixturePath)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	err = c.recordFixture(req, body, resp, fixturePath)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to record fixture: %w", err)
+	}
+	return resp, nil
+}
+
+func (c *fixtureClient) recordFixture(
+	req *http.Request,
+	reqBody []byte,
+	resp *http.Response,
+	fixturePath string,
+) error {
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	finalURL := req.URL.String()
+	if resp.Request != nil {
+		finalURL = resp.Request.URL.String()
+	}
+	fixture := &HTTPFixture{
+		Request: &FixtureRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: sanitizeHeader(req.Header),
+			Body:   string(reqBody),
+		},
+		Response: &FixtureResponse{
+			StatusCode: resp.StatusCode,
+			URL:        finalURL,
+			Header:     sanitizeHeader(resp.Header),
+		},
+	}
+	if utf8.Valid(respBody) {
+		fixture.Response.Body = string(respBody)
+	} else {
+		fixture.Response.Body = base64.StdEncoding.EncodeToString(respBody)
+		fixture.Response.BodyBase64 = true
+	}
+	data, err := sonic.ConfigStd.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fixturesMu.Lock()
+	defer fixturesMu.Unlock()
+	err = os.MkdirAll(filepath.Dir(fixturePath), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(fixturePath, data, 0644)
+	if err != nil {
+		return err
+	}
+	return c.recordCookieNames(req.Cookies())
+}
+
+// recordCookieNames stores the names of the cookies sent
+// by the extractor, so replays can use redacted ones
+func (c *fixtureClient) recordCookieNames(cookies []*http.Cookie) error {
+	if len(cookies) == 0 {
+		return nil
+	}
+	names := readFixtureCookieNames(c.extractor)
+	for _, cookie := range cookies {
+		if !slices.Contains(names, cookie.Name) {
+			names = append(names, cookie.Name)
+		}
+	}
+	slices.Sort(names)
+	data, err := sonic.ConfigStd.MarshalIndent(names, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fixtureCookiesPath(c.extractor), data, 0644)
+}
+
+func (c *fixtureClient) replayFixture(
+	req *http.Request,
+	fixturePath string,
+) (*http.Response, error) {
+	fixture, err := readFixture(fixturePath)
+	if os.IsNotExist(err) {
+		fixture, fixturePath = c.findClosestFixture(req)
+		if fixture == nil {
+			return nil, fmt.Errorf("no fixture for %s %s", req.Method, req.URL)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	body := []byte(fixture.Response.Body)
+	if fixture.Response.BodyBase64 {
+		body, err = base64.StdEncoding.DecodeString(fixture.Response.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode fixture %s: %w", fixturePath, err)
+		}
+	}
+	// extractors read the url after redirects
+	finalReq := req
+	if fixture.Response.URL != "" && fixture.Response.URL != req.URL.String() {
+		finalURL, err := url.Parse(fixture.Response.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url in fixture %s: %w", fixturePath, err)
+		}
+		finalReq = req.Clone(req.Context())
+		finalReq.URL = finalURL
+	}
+	header := fixture.Response.Header
+	if header == nil {
+		header = make(http.Header)
+	}
+	statusCode := fixture.Response.StatusCode
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       finalReq,
+	}, nil
+}
+
+// findClosestFixture returns the fixture with the same method
+// and path as the request, sharing most query values. queries
+// often have timestamps or signatures that change between runs
+func (c *fixtureClient) findClosestFixture(req *http.Request) (*HTTPFixture, string) {
+	paths, _ := filepath.Glob(filepath.Join(GetFixturesDir(), c.extractor, "http", "*.json"))
+	query := req.URL.Query()
+
+	var closest *HTTPFixture
+	var closestPath string
+	bestScore := -1
+	for _, path := range paths {
+		fixture, err := readFixture(path)
+		if err != nil || fixture.Request.Method != req.Method {
+			continue
+		}
+		fixtureURL, err := url.Parse(fixture.Request.URL)
+		if err != nil ||
+			fixtureURL.Host != req.URL.Host ||
+			fixtureURL.Path != req.URL.Path {
+			continue
+		}
+		var score int
+		for key, values := range fixtureURL.Query() {
+			if slices.Equal(query[key], values) {
+				score++
+			}
+		}
+		if score > bestScore {
+			closest, closestPath, bestScore = fixture, path, score
+		}
+	}
+	return closest, closestPath
+}
+
+func readFixture(fixturePath string) (*HTTPFixture, error) {
+	data, err := os.ReadFile(fixturePath)
+	if err != nil {
+		return nil, err
+	}
+	var fixture HTTPFixture
+	err = sonic.ConfigFastest.Unmarshal(data, &fixture)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", fixturePath, err)
+	}
+	if fixture.Request == nil || fixture.Response == nil {
+		return nil, fmt.Errorf("fixture %s is incomplete", fixturePath)
+	}
+	return &fixture, nil
+}
+
+// replayCookieJar returns a jar with redacted values for
+// the cookies recorded with the platform fixtures
+func replayCookieJar(platform string) (*CookieJar, error) {
+	names := readFixtureCookieNames(platform)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("failed to open cookie file: no cookies recorded for %s", platform)
+	}
+	cookies := make([]*http.Cookie, 0, len(names))
+	for _, name := range names {
+		cookies = append(cookies, &http.Cookie{
+			Name:  name,
+			Value: redactedValue,
+		})
+	}
+	return &CookieJar{
+		platform:      platform,
+		replayCookies: cookies,
+	}, nil
+}
+
+func readFixtureCookieNames(extractor string) []string {
+	data, err := os.ReadFile(fixtureCookiesPath(extractor))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	err = sonic.ConfigFastest.Unmarshal(data, &names)
+	if err != nil {
+		return nil
+	}
+	return names
+}
+
+func fixtureCookiesPath(extractor string) string {
+	return filepath.Join(GetFixturesDir(), extractor, "cookies.json")
+}
+
+// fixtureKey identifies a request by its
+// method, url and body. headers are ignored
+func fixtureKey(method string, rawURL string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + rawURL + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+func sanitizeHeader(header http.Header) http.Header {
+	sanitized := header.Clone()
+	for _, name := range sensitiveHeaders {
+		sanitized.Del(name)
+	}
+	return sanitized
+}
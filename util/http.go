@@ -2,9 +2,9 @@ package util
 
 import (
 	"log"
-	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +17,7 @@ var (
 	defaultClient     *http.Client
 	defaultClientOnce sync.Once
 	extractorClients  = make(map[string]models.HTTPClient)
+	extractorMu       sync.Mutex
 )
 
 func GetDefaultHTTPClient() *http.Client {
@@ -30,12 +31,9 @@ func GetDefaultHTTPClient() *http.Client {
 }
 
 func GetBaseTransport() *http.Transport {
-	return &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           newDirectDialer().DialContext,
 		ForceAttemptHTTP2:     true,
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
@@ -46,9 +44,27 @@ func GetBaseTransport() *http.Transport {
 		ResponseHeaderTimeout: 10 * time.Second,
 		DisableCompression:    false,
 	}
+	if proxyURL := getEnvSOCKSProxy(); proxyURL != nil {
+		noProxyList := parseNoProxyList(os.Getenv("NO_PROXY"))
+		err := configureSOCKSTransport(transport, proxyURL, noProxyList)
+		if err != nil {
+			log.Printf("warning: invalid socks5 proxy '%s': %v\n", proxyURL.Redacted(), err)
+		}
+	}
+	return transport
 }
 
+// GetHTTPClient returns the client of the extractor. with
+// HTTP_FIXTURES_MODE env, its requests are recorded or replayed
 func GetHTTPClient(extractor string) models.HTTPClient {
+	return WithFixtures(extractor, getExtractorClient(extractor))
+}
+
+func getExtractorClient(extractor string) models.HTTPClient {
+	// clients are also requested by concurrent downloads
+	extractorMu.Lock()
+	defer extractorMu.Unlock()
+
 	if client, exists := extractorClients[extractor]; exists {
 		return client
 	}
@@ -57,26 +73,113 @@ func GetHTTPClient(extractor string) models.HTTPClient {
 	if cfg == nil {
 		return GetDefaultHTTPClient()
 	}
+	client := newExtractorClient(extractor, cfg)
+	extractorClients[extractor] = client
+	return client
+}
 
-	var client models.HTTPClient
+// RebuildHTTPClients replaces the clients of the given
+// extractors with clients built from their current config
+func RebuildHTTPClients(extractors []string) {
+	// build the new clients first, so requests
+	// never see a missing or half-built client
+	clients := make(map[string]models.HTTPClient, len(extractors))
+	for _, extractor := range extractors {
+		cfg := config.GetExtractorConfig(extractor)
+		if cfg != nil {
+			clients[extractor] = newExtractorClient(extractor, cfg)
+		}
+	}
+
+	extractorMu.Lock()
+	var oldClients []models.HTTPClient
+	for _, extractor := range extractors {
+		if client, exists := extractorClients[extractor]; exists {
+			oldClients = append(oldClients, client)
+		}
+		if client, exists := clients[extractor]; exists {
+			extractorClients[extractor] = client
+		} else {
+			delete(extractorClients, extractor)
+		}
+	}
+	extractorMu.Unlock()
+
+	// running requests can still use old clients,
+	// only their proxy health checks are stopped
+	for _, client := range oldClients {
+		httpClient, ok := client.(*http.Client)
+		if !ok {
+			continue
+		}
+		if transport, ok := httpClient.Transport.(*proxyPoolTransport); ok {
+			transport.pool.Close()
+		}
+	}
+}
 
+func newExtractorClient(
+	extractor string,
+	cfg *models.ExtractorConfig,
+) models.HTTPClient {
 	if cfg.EdgeProxyURL != "" {
-		client = NewEdgeProxyFromConfig(cfg)
-	} else {
-		client = NewClientFromConfig(cfg)
+		return NewEdgeProxyFromConfig(cfg)
 	}
-	extractorClients[extractor] = client
-	return client
+	return NewClientFromConfig(extractor, cfg)
+}
+
+// GetDownloadHTTPClient returns the client used to download
+// media of the extractor, sharing its transport and proxies.
+// the client timeout is removed, since downloads set their own
+func GetDownloadHTTPClient(extractor string) models.HTTPClient {
+	switch client := getExtractorClient(extractor).(type) {
+	case *http.Client:
+		downloadClient := *client
+		downloadClient.Timeout = 0
+		return &downloadClient
+	default:
+		// edge proxy responses are text,
+		// they can't carry binary media
+		return nil
+	}
+}
+
+// FormatDownloadConfig returns a copy of config (or the default one)
+// set up to download the format with the extractor client
+func FormatDownloadConfig(
+	config *models.DownloadConfig,
+	extractor string,
+	format *models.MediaFormat,
+) *models.DownloadConfig {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	formatConfig := *config
+	formatConfig.HTTPClient = GetDownloadHTTPClient(extractor)
+	formatConfig.Headers = format.DownloadHeaders
+	formatConfig.Cookies = format.DownloadCookies
+	return &formatConfig
 }
 
-func NewClientFromConfig(cfg *models.ExtractorConfig) *http.Client {
+func NewClientFromConfig(
+	name string,
+	cfg *models.ExtractorConfig,
+) *http.Client {
 	var baseClient *http.Client
 	if cfg.Impersonate {
 		baseClient = NewChromeClient()
 	} else {
-		baseClient = GetDefaultHTTPClient()
+		// copy the default client, its
+		// transport is replaced below
+		defaultClient := *GetDefaultHTTPClient()
+		baseClient = &defaultClient
 	}
 	transport := GetBaseTransport()
+	// a proxy pool takes precedence over single proxies
+	if pool := NewProxyPool(name, cfg); pool != nil {
+		baseClient.Transport = pool.Transport(transport)
+		return baseClient
+	}
 	if cfg.HTTPProxy != "" || cfg.HTTPSProxy != "" {
 		configureProxyTransport(transport, cfg)
 	}
@@ -107,6 +210,21 @@ func configureProxyTransport(
 		return
 	}
 	noProxyList := parseNoProxyList(cfg.NoProxy)
+
+	// socks5 proxies work at the connection level, where the
+	// scheme is unknown, so the https one takes precedence
+	for _, proxyURL := range []*url.URL{httpsProxyURL, httpProxyURL} {
+		if !isSOCKSProxy(proxyURL) {
+			continue
+		}
+		err := configureSOCKSTransport(transport, proxyURL, noProxyList)
+		if err != nil {
+			log.Printf("warning: invalid socks5 proxy '%s': %v\n", proxyURL.Redacted(), err)
+		}
+		return
+	}
+	// the env socks5 proxy is replaced by the extractor proxies
+	transport.DialContext = newDirectDialer().DialContext
 	transport.Proxy = func(req *http.Request) (*url.URL, error) {
 		if shouldBypassProxy(req.URL.Hostname(), noProxyList) {
 			return nil, nil
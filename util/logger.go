@@ -0,0 +1,54 @@
+package util
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type loggerContextKey struct{}
+
+// SetupLogger sets the default logger from LOG_LEVEL
+// (debug, info, warn, error) and LOG_FORMAT (text, json)
+// env. the standard log package writes to it too
+func SetupLogger() {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL")))
+	if err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(os.Getenv("LOG_FORMAT")) {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+	if err != nil && os.Getenv("LOG_LEVEL") != "" {
+		log.Println("failed to parse LOG_LEVEL env, using info")
+	}
+}
+
+// WithLogger returns a copy of ctx carrying the logger,
+// so everything done for a request logs its attributes
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// GetLogger returns the logger of ctx,
+// or the default one if there is none
+func GetLogger(ctx context.Context) *slog.Logger {
+	if ctx == nil {
+		return slog.Default()
+	}
+	logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger)
+	if !ok {
+		return slog.Default()
+	}
+	return logger
+}
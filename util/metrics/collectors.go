@@ -0,0 +1,48 @@
+package metrics
+
+var (
+	ExtractorRuns = NewCounter(
+		"govd_extractor_runs_total",
+		"extractor runs by extractor and outcome (success, error kind or error)",
+		"extractor", "outcome",
+	)
+	ExtractorDuration = NewHistogram(
+		"govd_extractor_run_duration_seconds",
+		"duration of extractor runs",
+		DefaultBuckets,
+		"extractor",
+	)
+	DownloadedBytes = NewCounter(
+		"govd_downloaded_bytes_total",
+		"bytes downloaded from media urls",
+	)
+	ChunkRetries = NewCounter(
+		"govd_download_chunk_retries_total",
+		"retried download chunks and streams",
+	)
+	DownloadedSegments = NewCounter(
+		"govd_downloaded_segments_total",
+		"hls and dash segments downloaded",
+	)
+	ProcessingDuration = NewHistogram(
+		"govd_processing_duration_seconds",
+		"duration of ffmpeg and astiav steps",
+		DefaultBuckets,
+		"step",
+	)
+	Uploads = NewCounter(
+		"govd_telegram_uploads_total",
+		"media uploads to telegram by outcome",
+		"outcome",
+	)
+	UploadDuration = NewHistogram(
+		"govd_telegram_upload_duration_seconds",
+		"latency of media uploads to telegram",
+		DefaultBuckets,
+	)
+	CacheLookups = NewCounter(
+		"govd_cache_lookups_total",
+		"lookups of already uploaded media by result (hit or miss)",
+		"result",
+	)
+)
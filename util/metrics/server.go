@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const checkTimeout = 5 * time.Second
+
+// Check returns an error if a dependency is not working
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+var (
+	healthChecks    []namedCheck
+	readinessChecks []namedCheck
+	checksMu        sync.Mutex
+	ready           atomic.Bool
+)
+
+// AddHealthCheck adds a check to both /healthz and /readyz
+func AddHealthCheck(name string, check Check) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	healthChecks = append(healthChecks, namedCheck{name, check})
+}
+
+// AddReadinessCheck adds a check to /readyz only
+func AddReadinessCheck(name string, check Check) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	readinessChecks = append(readinessChecks, namedCheck{name, check})
+}
+
+// SetReady marks the bot as ready to receive updates.
+// /readyz fails until then
+func SetReady(value bool) {
+	ready.Store(value)
+}
+
+// Start serves /metrics, /healthz and /readyz on the port
+func Start(port int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		checksMu.Lock()
+		checks := healthChecks
+		checksMu.Unlock()
+		runChecks(w, r, checks)
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		checksMu.Lock()
+		checks := slices.Concat(healthChecks, readinessChecks)
+		checksMu.Unlock()
+		runChecks(w, r, checks)
+	})
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("starting metrics server on port %d", port)
+	err := server.ListenAndServe()
+	if err != nil {
+		log.Printf("metrics server stopped: %v", err)
+	}
+}
+
+func runChecks(
+	w http.ResponseWriter,
+	r *http.Request,
+	checks []namedCheck,
+) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	for _, check := range checks {
+		err := check.check(ctx)
+		if err != nil {
+			http.Error(
+				w,
+				fmt.Sprintf("%s: %v", check.name, err),
+				http.StatusServiceUnavailable,
+			)
+			return
+		}
+	}
+	fmt.Fprintln(w, "ok")
+}
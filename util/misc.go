@@ -14,11 +14,8 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
-	"github.com/aki237/nscjar"
 )
 
-var cookiesCache = make(map[string][]*http.Cookie)
-
 func GetLocationURL(
 	client models.HTTPClient,
 	url string,
@@ -134,27 +131,6 @@ func RandomAlphaString(length int) string {
 	return string(result)
 }
 
-func ParseCookieFile(fileName string) ([]*http.Cookie, error) {
-	cachedCookies, ok := cookiesCache[fileName]
-	if ok {
-		return cachedCookies, nil
-	}
-	cookiePath := filepath.Join("cookies", fileName)
-	cookieFile, err := os.Open(cookiePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open cookie file: %w", err)
-	}
-	defer cookieFile.Close()
-
-	var parser nscjar.Parser
-	cookies, err := parser.Unmarshal(cookieFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse cookie file: %w", err)
-	}
-	cookiesCache[fileName] = cookies
-	return cookies, nil
-}
-
 func FixURL(url string) string {
 	return strings.ReplaceAll(url, "&amp;", "&")
 }
@@ -165,10 +141,22 @@ func CheckFFmpeg() bool {
 }
 
 func CleanupDownloadsDir() {
-	downloadsDir := os.Getenv("DOWNLOAD_DIR")
-	if downloadsDir == "" {
-		downloadsDir = "downloads"
-	}
+	cleanupDownloadsDir(30*time.Minute, ResumableDownloadTTL)
+}
+
+// ClearDownloadsDir removes the files of all downloads,
+// keeping the partial ones of interrupted jobs to be resumed
+// after the restart. it must be called when no download is
+// running, e.g. on shutdown
+func ClearDownloadsDir() {
+	cleanupDownloadsDir(0, -1)
+}
+
+// cleanupDownloadsDir removes files older than maxFileAge, and
+// partial downloads older than resumableAge. a negative
+// resumableAge keeps them, until the next cleanup
+func cleanupDownloadsDir(maxFileAge time.Duration, resumableAge time.Duration) {
+	downloadsDir := DefaultConfig().DownloadDir
 	filepath.Walk(downloadsDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
@@ -176,7 +164,16 @@ func CleanupDownloadsDir() {
 		if path == downloadsDir {
 			return nil
 		}
-		if time.Since(info.ModTime()) > 30*time.Minute {
+		maxAge := maxFileAge
+		if isResumableFile(path) {
+			// partial downloads are kept to be
+			// resumed by jobs restored after a restart
+			if resumableAge < 0 {
+				return nil
+			}
+			maxAge = resumableAge
+		}
+		if time.Since(info.ModTime()) > maxAge {
 			if info.IsDir() {
 				os.RemoveAll(path)
 			} else {
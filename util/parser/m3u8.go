@@ -249,13 +249,13 @@ func getVideoCodec(codecs string) enums.MediaCodec {
 	switch {
 	case strings.Contains(codecs, "avc"), strings.Contains(codecs, "h264"):
 		return enums.MediaCodecAVC
-	case strings.Contains(codecs, "hvc"), strings.Contains(codecs, "h265"):
+	case strings.Contains(codecs, "hvc"), strings.Contains(codecs, "hev"), strings.Contains(codecs, "h265"):
 		return enums.MediaCodecHEVC
 	case strings.Contains(codecs, "av01"):
 		return enums.MediaCodecAV1
-	case strings.Contains(codecs, "vp9"):
+	case strings.Contains(codecs, "vp9"), strings.Contains(codecs, "vp09"):
 		return enums.MediaCodecVP9
-	case strings.Contains(codecs, "vp8"):
+	case strings.Contains(codecs, "vp8"), strings.Contains(codecs, "vp08"):
 		return enums.MediaCodecVP8
 	default:
 		return ""
@@ -1,3 +1,544 @@
 package parser
 
-// todo
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"govd/enums"
+	"govd/models"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	isoDurationPattern = regexp.MustCompile(
+		`^P(?:(\d+(?:\.\d+)?)Y)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)D)?` +
+			`(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+	templateIdentifierPattern = regexp.MustCompile(
+		`\$(RepresentationID|Number|Bandwidth|Time)(%0(\d+)d)?\$`)
+)
+
+type mpdManifest struct {
+	XMLName                   xml.Name     `xml:"MPD"`
+	Type                      string       `xml:"type,attr"`
+	MediaPresentationDuration string       `xml:"mediaPresentationDuration,attr"`
+	BaseURL                   string       `xml:"BaseURL"`
+	Periods                   []*mpdPeriod `xml:"Period"`
+}
+
+type mpdPeriod struct {
+	ID              string              `xml:"id,attr"`
+	Duration        string              `xml:"duration,attr"`
+	BaseURL         string              `xml:"BaseURL"`
+	AdaptationSets  []*mpdAdaptationSet `xml:"AdaptationSet"`
+	SegmentTemplate *mpdSegmentTemplate `xml:"SegmentTemplate"`
+	SegmentList     *mpdSegmentList     `xml:"SegmentList"`
+}
+
+type mpdAdaptationSet struct {
+	ID              string               `xml:"id,attr"`
+	ContentType     string               `xml:"contentType,attr"`
+	MimeType        string               `xml:"mimeType,attr"`
+	Codecs          string               `xml:"codecs,attr"`
+	Width           int64                `xml:"width,attr"`
+	Height          int64                `xml:"height,attr"`
+	BaseURL         string               `xml:"BaseURL"`
+	SegmentTemplate *mpdSegmentTemplate  `xml:"SegmentTemplate"`
+	SegmentList     *mpdSegmentList      `xml:"SegmentList"`
+	SegmentBase     *mpdSegmentBase      `xml:"SegmentBase"`
+	Representations []*mpdRepresentation `xml:"Representation"`
+}
+
+type mpdRepresentation struct {
+	ID              string              `xml:"id,attr"`
+	Bandwidth       int64               `xml:"bandwidth,attr"`
+	MimeType        string              `xml:"mimeType,attr"`
+	Codecs          string              `xml:"codecs,attr"`
+	Width           int64               `xml:"width,attr"`
+	Height          int64               `xml:"height,attr"`
+	BaseURL         string              `xml:"BaseURL"`
+	SegmentTemplate *mpdSegmentTemplate `xml:"SegmentTemplate"`
+	SegmentList     *mpdSegmentList     `xml:"SegmentList"`
+	SegmentBase     *mpdSegmentBase     `xml:"SegmentBase"`
+}
+
+type mpdSegmentTemplate struct {
+	Media           string              `xml:"media,attr"`
+	Initialization  string              `xml:"initialization,attr"`
+	StartNumber     *int64              `xml:"startNumber,attr"`
+	Timescale       int64               `xml:"timescale,attr"`
+	Duration        int64               `xml:"duration,attr"`
+	SegmentTimeline *mpdSegmentTimeline `xml:"SegmentTimeline"`
+}
+
+type mpdSegmentTimeline struct {
+	Segments []*mpdTimelineSegment `xml:"S"`
+}
+
+type mpdTimelineSegment struct {
+	Time     *int64 `xml:"t,attr"`
+	Duration int64  `xml:"d,attr"`
+	Repeat   int64  `xml:"r,attr"`
+}
+
+type mpdSegmentList struct {
+	Timescale      int64              `xml:"timescale,attr"`
+	Duration       int64              `xml:"duration,attr"`
+	Initialization *mpdInitialization `xml:"Initialization"`
+	SegmentURLs    []*mpdSegmentURL   `xml:"SegmentURL"`
+}
+
+type mpdSegmentBase struct {
+	IndexRange     string             `xml:"indexRange,attr"`
+	Initialization *mpdInitialization `xml:"Initialization"`
+}
+
+type mpdInitialization struct {
+	SourceURL string `xml:"sourceURL,attr"`
+	Range     string `xml:"range,attr"`
+}
+
+type mpdSegmentURL struct {
+	Media      string `xml:"media,attr"`
+	MediaRange string `xml:"mediaRange,attr"`
+}
+
+func ParseMPDContent(
+	content []byte,
+	baseURL string,
+) ([]*models.MediaFormat, error) {
+	baseURLObj, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	}
+
+	var manifest mpdManifest
+	if err := xml.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("failed parsing mpd: %w", err)
+	}
+	if manifest.Type == "dynamic" {
+		// live streams have no fixed list of segments
+		return nil, errors.New("dynamic mpd manifests are not supported")
+	}
+	if len(manifest.Periods) == 0 {
+		return nil, errors.New("no periods found in mpd")
+	}
+
+	manifestBaseURL := resolveBaseURL(baseURLObj, manifest.BaseURL)
+	manifestDuration := parseISODuration(manifest.MediaPresentationDuration)
+
+	var formats []*models.MediaFormat
+	for _, period := range manifest.Periods {
+		if period == nil {
+			continue
+		}
+		periodDuration := parseISODuration(period.Duration)
+		if periodDuration == 0 {
+			periodDuration = manifestDuration
+		}
+		periodBaseURL := resolveBaseURL(manifestBaseURL, period.BaseURL)
+		for _, adaptationSet := range period.AdaptationSets {
+			if adaptationSet == nil {
+				continue
+			}
+			formats = append(formats, parseAdaptationSet(
+				period,
+				adaptationSet,
+				periodBaseURL,
+				periodDuration,
+			)...)
+		}
+		// only the first period is used, multi-period
+		// manifests are usually ads or stream splicing
+		if len(formats) > 0 {
+			break
+		}
+	}
+	if len(formats) == 0 {
+		return nil, errors.New("no representations found in mpd")
+	}
+	return formats, nil
+}
+
+func ParseMPDFromURL(url string) ([]*models.MediaFormat, error) {
+	body, err := fetchContent(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch mpd content: %w", err)
+	}
+	return ParseMPDContent(body, url)
+}
+
+func parseAdaptationSet(
+	period *mpdPeriod,
+	adaptationSet *mpdAdaptationSet,
+	baseURL *url.URL,
+	duration float64,
+) []*models.MediaFormat {
+	var formats []*models.MediaFormat
+
+	adaptationBaseURL := resolveBaseURL(baseURL, adaptationSet.BaseURL)
+	for _, representation := range adaptationSet.Representations {
+		if representation == nil {
+			continue
+		}
+		codecs := firstNonEmpty(representation.Codecs, adaptationSet.Codecs)
+		mimeType := firstNonEmpty(representation.MimeType, adaptationSet.MimeType)
+		mediaType, videoCodec, audioCodec := parseRepresentationType(
+			adaptationSet.ContentType,
+			mimeType,
+			codecs,
+		)
+		if mediaType == "" {
+			// subtitles, thumbnails and other tracks
+			continue
+		}
+		width := representation.Width
+		if width == 0 {
+			width = adaptationSet.Width
+		}
+		height := representation.Height
+		if height == 0 {
+			height = adaptationSet.Height
+		}
+		representationBaseURL := resolveBaseURL(adaptationBaseURL, representation.BaseURL)
+
+		format := &models.MediaFormat{
+			FormatID:   "dash-" + representation.ID,
+			Type:       mediaType,
+			VideoCodec: videoCodec,
+			AudioCodec: audioCodec,
+			Bitrate:    representation.Bandwidth,
+			Width:      width,
+			Height:     height,
+			Duration:   int64(math.Round(duration)),
+			URL:        []string{representationBaseURL.String()},
+		}
+
+		segmentTemplate := mergeSegmentTemplates(
+			representation.SegmentTemplate,
+			adaptationSet.SegmentTemplate,
+			period.SegmentTemplate,
+		)
+		segmentList := mergeSegmentLists(
+			representation.SegmentList,
+			adaptationSet.SegmentList,
+			period.SegmentList,
+		)
+		switch {
+		case segmentTemplate != nil:
+			segments, segmentsDuration := expandSegmentTemplate(
+				segmentTemplate,
+				representation,
+				representationBaseURL,
+				duration,
+			)
+			format.Segments = segments
+			if format.Duration == 0 && segmentsDuration > 0 {
+				format.Duration = int64(math.Round(segmentsDuration))
+			}
+		case segmentList != nil:
+			format.Segments = expandSegmentList(
+				segmentList,
+				representationBaseURL,
+			)
+			if format.Duration == 0 && segmentList.Duration > 0 {
+				timescale := segmentList.Timescale
+				if timescale == 0 {
+					timescale = 1
+				}
+				totalDuration := float64(segmentList.Duration) *
+					float64(len(segmentList.SegmentURLs)) /
+					float64(timescale)
+				format.Duration = int64(math.Round(totalDuration))
+			}
+		default:
+			// SegmentBase or plain BaseURL, the whole
+			// representation is a single downloadable file
+		}
+		if len(format.Segments) == 0 &&
+			(segmentTemplate != nil || segmentList != nil || !representationBaseURL.IsAbs()) {
+			// representation has no addressable media
+			continue
+		}
+		formats = append(formats, format)
+	}
+	return formats
+}
+
+func expandSegmentTemplate(
+	template *mpdSegmentTemplate,
+	representation *mpdRepresentation,
+	baseURL *url.URL,
+	duration float64,
+) ([]string, float64) {
+	var segments []string
+
+	timescale := template.Timescale
+	if timescale == 0 {
+		timescale = 1
+	}
+	number := int64(1)
+	if template.StartNumber != nil {
+		number = *template.StartNumber
+	}
+
+	if template.Initialization != "" {
+		initURL := fillSegmentTemplate(
+			template.Initialization,
+			representation, 0, 0,
+		)
+		segments = append(segments, resolveURL(baseURL, initURL))
+	}
+	if template.Media == "" {
+		return segments, 0
+	}
+
+	var totalTime int64
+	if template.SegmentTimeline != nil && len(template.SegmentTimeline.Segments) > 0 {
+		var currentTime int64
+		for _, segment := range template.SegmentTimeline.Segments {
+			if segment == nil {
+				continue
+			}
+			if segment.Time != nil {
+				currentTime = *segment.Time
+			}
+			repeat := segment.Repeat
+			if repeat < 0 {
+				// repeat until the end of the period
+				if duration > 0 && segment.Duration > 0 {
+					remaining := duration*float64(timescale) - float64(currentTime)
+					repeat = int64(math.Ceil(remaining/float64(segment.Duration))) - 1
+				} else {
+					repeat = 0
+				}
+			}
+			for range repeat + 1 {
+				mediaURL := fillSegmentTemplate(
+					template.Media,
+					representation,
+					number, currentTime,
+				)
+				segments = append(segments, resolveURL(baseURL, mediaURL))
+				currentTime += segment.Duration
+				totalTime += segment.Duration
+				number++
+			}
+		}
+		return segments, float64(totalTime) / float64(timescale)
+	}
+
+	if template.Duration == 0 || duration == 0 {
+		return segments, 0
+	}
+	segmentDuration := float64(template.Duration) / float64(timescale)
+	segmentCount := int64(math.Ceil(duration / segmentDuration))
+	for i := range segmentCount {
+		mediaURL := fillSegmentTemplate(
+			template.Media,
+			representation,
+			number+i,
+			i*template.Duration,
+		)
+		segments = append(segments, resolveURL(baseURL, mediaURL))
+	}
+	return segments, duration
+}
+
+func expandSegmentList(
+	segmentList *mpdSegmentList,
+	baseURL *url.URL,
+) []string {
+	var segments []string
+	if segmentList.Initialization != nil && segmentList.Initialization.SourceURL != "" {
+		segments = append(segments, resolveURL(baseURL, segmentList.Initialization.SourceURL))
+	}
+	for _, segmentURL := range segmentList.SegmentURLs {
+		if segmentURL == nil {
+			continue
+		}
+		if segmentURL.Media == "" {
+			// byterange not supported
+			break
+		}
+		segments = append(segments, resolveURL(baseURL, segmentURL.Media))
+	}
+	return segments
+}
+
+func fillSegmentTemplate(
+	template string,
+	representation *mpdRepresentation,
+	number int64,
+	time int64,
+) string {
+	result := templateIdentifierPattern.ReplaceAllStringFunc(template, func(match string) string {
+		groups := templateIdentifierPattern.FindStringSubmatch(match)
+		var value string
+		switch groups[1] {
+		case "RepresentationID":
+			return representation.ID
+		case "Number":
+			value = strconv.FormatInt(number, 10)
+		case "Bandwidth":
+			value = strconv.FormatInt(representation.Bandwidth, 10)
+		case "Time":
+			value = strconv.FormatInt(time, 10)
+		}
+		if groups[3] != "" {
+			width, err := strconv.Atoi(groups[3])
+			if err == nil && len(value) < width {
+				value = strings.Repeat("0", width-len(value)) + value
+			}
+		}
+		return value
+	})
+	return strings.ReplaceAll(result, "$$", "$")
+}
+
+func parseRepresentationType(
+	contentType string,
+	mimeType string,
+	codecs string,
+) (enums.MediaType, enums.MediaCodec, enums.MediaCodec) {
+	var mediaType enums.MediaType
+
+	videoCodec := getVideoCodec(codecs)
+	audioCodec := getAudioCodec(codecs)
+
+	switch {
+	case contentType == "video", strings.HasPrefix(mimeType, "video/"):
+		mediaType = enums.MediaTypeVideo
+	case contentType == "audio", strings.HasPrefix(mimeType, "audio/"):
+		mediaType = enums.MediaTypeAudio
+	case contentType == "image", strings.HasPrefix(mimeType, "image/"):
+		mediaType = enums.MediaTypePhoto
+	case videoCodec != "":
+		mediaType = enums.MediaTypeVideo
+	case audioCodec != "":
+		mediaType = enums.MediaTypeAudio
+	}
+
+	if mediaType == enums.MediaTypeAudio {
+		videoCodec = ""
+	}
+	if mediaType == enums.MediaTypePhoto {
+		// image adaptation sets are thumbnail tiles
+		return "", "", ""
+	}
+
+	return mediaType, videoCodec, audioCodec
+}
+
+func parseISODuration(duration string) float64 {
+	if duration == "" {
+		return 0
+	}
+	matches := isoDurationPattern.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0
+	}
+	multipliers := []float64{
+		365 * 24 * 3600, // years
+		30 * 24 * 3600,  // months
+		24 * 3600,       // days
+		3600,            // hours
+		60,              // minutes
+		1,               // seconds
+	}
+	var total float64
+	for i, multiplier := range multipliers {
+		if matches[i+1] == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(matches[i+1], 64)
+		if err != nil {
+			return 0
+		}
+		total += value * multiplier
+	}
+	return total
+}
+
+func resolveBaseURL(base *url.URL, baseURL string) *url.URL {
+	baseURL = strings.TrimSpace(baseURL)
+	if baseURL == "" {
+		return base
+	}
+	ref, err := url.Parse(baseURL)
+	if err != nil {
+		return base
+	}
+	return base.ResolveReference(ref)
+}
+
+// mergeSegmentTemplates returns the template inherited by a
+// representation, from the most specific level to the least.
+// each attribute is taken from the first level setting it
+func mergeSegmentTemplates(templates ...*mpdSegmentTemplate) *mpdSegmentTemplate {
+	var merged *mpdSegmentTemplate
+	for _, template := range templates {
+		if template == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &mpdSegmentTemplate{}
+		}
+		merged.Media = firstNonEmpty(merged.Media, template.Media)
+		merged.Initialization = firstNonEmpty(merged.Initialization, template.Initialization)
+		if merged.StartNumber == nil {
+			merged.StartNumber = template.StartNumber
+		}
+		if merged.Timescale == 0 {
+			merged.Timescale = template.Timescale
+		}
+		if merged.Duration == 0 {
+			merged.Duration = template.Duration
+		}
+		if merged.SegmentTimeline == nil {
+			merged.SegmentTimeline = template.SegmentTimeline
+		}
+	}
+	return merged
+}
+
+// mergeSegmentLists works like mergeSegmentTemplates
+// for segment lists
+func mergeSegmentLists(lists ...*mpdSegmentList) *mpdSegmentList {
+	var merged *mpdSegmentList
+	for _, list := range lists {
+		if list == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &mpdSegmentList{}
+		}
+		if merged.Timescale == 0 {
+			merged.Timescale = list.Timescale
+		}
+		if merged.Duration == 0 {
+			merged.Duration = list.Duration
+		}
+		if merged.Initialization == nil {
+			merged.Initialization = list.Initialization
+		}
+		if len(merged.SegmentURLs) == 0 {
+			merged.SegmentURLs = list.SegmentURLs
+		}
+	}
+	return merged
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,187 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"govd/enums"
+)
+
+func TestParseMPDContent(t *testing.T) {
+	type expectedFormat struct {
+		formatID  string
+		mediaType enums.MediaType
+		duration  int64
+		url       string
+		segments  []string
+	}
+	tests := []struct {
+		name     string
+		manifest string
+		expected []expectedFormat
+	}{
+		{
+			// media and initialization are set on the adaptation
+			// set, timescale and startNumber on the representation
+			name:     "segment template with number",
+			manifest: "template_number.mpd",
+			expected: []expectedFormat{
+				{
+					formatID:  "dash-720p",
+					mediaType: enums.MediaTypeVideo,
+					duration:  10,
+					segments: []string{
+						"https://cdn.example.com/video/720p/init.mp4",
+						"https://cdn.example.com/video/720p/seg-00001.m4s",
+						"https://cdn.example.com/video/720p/seg-00002.m4s",
+						"https://cdn.example.com/video/720p/seg-00003.m4s",
+					},
+				},
+				{
+					formatID:  "dash-480p",
+					mediaType: enums.MediaTypeVideo,
+					duration:  10,
+					segments: []string{
+						"https://cdn.example.com/video/480p/init.mp4",
+						"https://cdn.example.com/video/480p/seg-00000.m4s",
+						"https://cdn.example.com/video/480p/seg-00001.m4s",
+						"https://cdn.example.com/video/480p/seg-00002.m4s",
+					},
+				},
+				{
+					formatID:  "dash-audio",
+					mediaType: enums.MediaTypeAudio,
+					duration:  10,
+					segments: []string{
+						"https://cdn.example.com/video/audio/128000/init.mp4",
+						"https://cdn.example.com/video/audio/128000/1.m4s",
+						"https://cdn.example.com/video/audio/128000/2.m4s",
+						"https://cdn.example.com/video/audio/128000/3.m4s",
+					},
+				},
+			},
+		},
+		{
+			// the video timescale is inherited from the period
+			name:     "segment template with timeline",
+			manifest: "template_timeline.mpd",
+			expected: []expectedFormat{
+				{
+					formatID:  "dash-1080",
+					mediaType: enums.MediaTypeVideo,
+					duration:  10,
+					segments: []string{
+						"https://vod.example.com/1234/video_1080_init.mp4",
+						"https://vod.example.com/1234/video_1080_0.mp4",
+						"https://vod.example.com/1234/video_1080_360000.mp4",
+						"https://vod.example.com/1234/video_1080_720000.mp4",
+					},
+				},
+				{
+					formatID:  "dash-audio",
+					mediaType: enums.MediaTypeAudio,
+					duration:  10,
+					segments: []string{
+						"https://vod.example.com/1234/audio_init.mp4",
+						"https://vod.example.com/1234/audio_1.mp4",
+						"https://vod.example.com/1234/audio_2.mp4",
+						"https://vod.example.com/1234/audio_3.mp4",
+					},
+				},
+			},
+		},
+		{
+			// the initialization is set on the adaptation
+			// set, the segment urls on the representation
+			name:     "segment list",
+			manifest: "segment_list.mpd",
+			expected: []expectedFormat{
+				{
+					formatID:  "dash-v1",
+					mediaType: enums.MediaTypeVideo,
+					duration:  6,
+					segments: []string{
+						"https://media.example.com/clip/540p/init.mp4",
+						"https://media.example.com/clip/540p/1.m4s",
+						"https://media.example.com/clip/540p/2.m4s",
+						"https://media.example.com/clip/540p/3.m4s",
+					},
+				},
+			},
+		},
+		{
+			// the representation is a single file,
+			// with the base url set on the period
+			name:     "segment base",
+			manifest: "segment_base.mpd",
+			expected: []expectedFormat{
+				{
+					formatID:  "dash-audio",
+					mediaType: enums.MediaTypeAudio,
+					duration:  12,
+					url:       "https://video.example.com/5678/audio_128k.mp4",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("testdata", test.manifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			formats, err := ParseMPDContent(content, "https://example.com/manifest.mpd")
+			if err != nil {
+				t.Fatalf("ParseMPDContent() error = %v", err)
+			}
+			if len(formats) != len(test.expected) {
+				t.Fatalf("got %d formats, want %d", len(formats), len(test.expected))
+			}
+			for i, expected := range test.expected {
+				format := formats[i]
+				if format.FormatID != expected.formatID {
+					t.Errorf("format %d: id = %s, want %s", i, format.FormatID, expected.formatID)
+				}
+				if format.Type != expected.mediaType {
+					t.Errorf("%s: type = %s, want %s", expected.formatID, format.Type, expected.mediaType)
+				}
+				if format.Duration != expected.duration {
+					t.Errorf("%s: duration = %d, want %d", expected.formatID, format.Duration, expected.duration)
+				}
+				if expected.url != "" && !slices.Equal(format.URL, []string{expected.url}) {
+					t.Errorf("%s: url = %v, want %s", expected.formatID, format.URL, expected.url)
+				}
+				if !slices.Equal(format.Segments, expected.segments) {
+					t.Errorf("%s: segments = %v, want %v", expected.formatID, format.Segments, expected.segments)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeSegmentTemplates(t *testing.T) {
+	startNumber := int64(5)
+	merged := mergeSegmentTemplates(
+		&mpdSegmentTemplate{StartNumber: &startNumber, Timescale: 1000},
+		nil,
+		&mpdSegmentTemplate{Media: "$Number$.m4s", Timescale: 90000, Duration: 4000},
+	)
+	if merged.Media != "$Number$.m4s" {
+		t.Errorf("media = %q, want inherited one", merged.Media)
+	}
+	if merged.StartNumber == nil || *merged.StartNumber != startNumber {
+		t.Errorf("startNumber = %v, want %d", merged.StartNumber, startNumber)
+	}
+	if merged.Timescale != 1000 {
+		t.Errorf("timescale = %d, want the most specific one", merged.Timescale)
+	}
+	if merged.Duration != 4000 {
+		t.Errorf("duration = %d, want 4000", merged.Duration)
+	}
+	if mergeSegmentTemplates(nil, nil) != nil {
+		t.Error("merge of missing templates is not nil")
+	}
+}
@@ -0,0 +1,314 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"govd/models"
+)
+
+const (
+	ProxyStrategyRoundRobin = "round_robin"
+	ProxyStrategyRandom     = "random"
+	ProxyStrategySticky     = "sticky"
+)
+
+const (
+	defaultProxyCooldown       = 5 * time.Minute
+	defaultProxyHealthInterval = time.Minute
+	defaultProxyHealthURL      = "https://www.gstatic.com/generate_204"
+	// consecutive failures double the cooldown, up to 16 times
+	maxProxyCooldownShift = 4
+	// consecutive request errors before a proxy is ejected
+	maxProxyRequestErrors = 3
+)
+
+type proxyKeyContextKey struct{}
+type pooledProxyContextKey struct{}
+
+// ProxyPool rotates requests between proxies, ejecting
+// the ones that fail until their cooldown expires
+type ProxyPool struct {
+	name           string
+	proxies        []*pooledProxy
+	strategy       string
+	cooldown       time.Duration
+	healthURL      string
+	healthInterval time.Duration
+	noProxyList    []string
+
+	mu   sync.Mutex
+	next int
+	stop chan struct{}
+}
+
+type pooledProxy struct {
+	url          *url.URL
+	failures     int
+	ejectedUntil time.Time
+	// request errors since the last success
+	requestErrors int
+}
+
+// proxyPoolTransport picks a proxy for each request
+// and reports the outcome back to the pool
+type proxyPoolTransport struct {
+	pool *ProxyPool
+	base *http.Transport
+}
+
+// WithProxyKey returns a copy of ctx carrying the key
+// used by the sticky strategy, such as a content ID.
+// only requests created with the context use the key
+func WithProxyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, proxyKeyContextKey{}, key)
+}
+
+// NewProxyPool returns a pool for the proxies of the config,
+// or nil if there are none. the pool checks its proxies
+// periodically until Close is called
+func NewProxyPool(
+	name string,
+	cfg *models.ExtractorConfig,
+) *ProxyPool {
+	var proxies []*pooledProxy
+	for _, rawURL := range cfg.Proxies {
+		proxyURL, err := url.Parse(rawURL)
+		if err != nil || proxyURL.Host == "" {
+			slog.Warn("invalid proxy url", "extractor", name, "url", rawURL, "error", err)
+			continue
+		}
+		proxies = append(proxies, &pooledProxy{url: proxyURL})
+	}
+	if len(proxies) == 0 {
+		return nil
+	}
+
+	pool := &ProxyPool{
+		name:           name,
+		proxies:        proxies,
+		strategy:       cfg.ProxyStrategy,
+		cooldown:       time.Duration(cfg.ProxyCooldown) * time.Second,
+		healthURL:      cfg.ProxyHealthURL,
+		healthInterval: time.Duration(cfg.ProxyHealthInterval) * time.Second,
+		noProxyList:    parseNoProxyList(cfg.NoProxy),
+		stop:           make(chan struct{}),
+	}
+	switch pool.strategy {
+	case ProxyStrategyRoundRobin, ProxyStrategyRandom, ProxyStrategySticky:
+	default:
+		if pool.strategy != "" {
+			slog.Warn(
+				"unknown proxy strategy",
+				"extractor", name,
+				"strategy", pool.strategy,
+				"fallback", ProxyStrategyRoundRobin,
+			)
+		}
+		pool.strategy = ProxyStrategyRoundRobin
+	}
+	if pool.cooldown <= 0 {
+		pool.cooldown = defaultProxyCooldown
+	}
+	if pool.healthURL == "" {
+		pool.healthURL = defaultProxyHealthURL
+	}
+	if pool.healthInterval <= 0 {
+		pool.healthInterval = defaultProxyHealthInterval
+	}
+	go pool.runHealthChecks()
+	return pool
+}
+
+// Transport returns a round tripper sending
+// requests through the pool with the base transport
+func (pool *ProxyPool) Transport(base *http.Transport) http.RoundTripper {
+	// the env socks5 proxy is replaced by the pool
+	base.DialContext = newDirectDialer().DialContext
+	base.Proxy = func(req *http.Request) (*url.URL, error) {
+		proxy, ok := req.Context().Value(pooledProxyContextKey{}).(*pooledProxy)
+		if !ok {
+			return nil, nil
+		}
+		return proxy.url, nil
+	}
+	return &proxyPoolTransport{
+		pool: pool,
+		base: base,
+	}
+}
+
+// Close stops the health checks
+func (pool *ProxyPool) Close() {
+	close(pool.stop)
+}
+
+func (t *proxyPoolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if shouldBypassProxy(req.URL.Hostname(), t.pool.noProxyList) {
+		return t.base.RoundTrip(req)
+	}
+	key, _ := req.Context().Value(proxyKeyContextKey{}).(string)
+	proxy := t.pool.pick(key)
+	ctx := context.WithValue(req.Context(), pooledProxyContextKey{}, proxy)
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	switch {
+	case err != nil:
+		// canceled requests and expired deadlines of
+		// the caller say nothing about the proxy
+		if req.Context().Err() != nil ||
+			errors.Is(err, context.Canceled) ||
+			errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		t.pool.fail(req.Context(), proxy, err.Error())
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusForbidden,
+		resp.StatusCode == http.StatusProxyAuthRequired:
+		t.pool.eject(req.Context(), proxy, resp.Status)
+	default:
+		t.pool.succeed(proxy)
+	}
+	return resp, err
+}
+
+// pick returns the proxy for the next request.
+// if all proxies are ejected, the one whose
+// cooldown expires first is used
+func (pool *ProxyPool) pick(key string) *pooledProxy {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	now := time.Now()
+	available := make([]*pooledProxy, 0, len(pool.proxies))
+	for _, proxy := range pool.proxies {
+		if now.After(proxy.ejectedUntil) {
+			available = append(available, proxy)
+		}
+	}
+	if len(available) == 0 {
+		first := pool.proxies[0]
+		for _, proxy := range pool.proxies[1:] {
+			if proxy.ejectedUntil.Before(first.ejectedUntil) {
+				first = proxy
+			}
+		}
+		return first
+	}
+
+	switch {
+	case pool.strategy == ProxyStrategyRandom:
+		return available[rand.IntN(len(available))]
+	case pool.strategy == ProxyStrategySticky && key != "":
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		return available[hash.Sum32()%uint32(len(available))]
+	default:
+		pool.next = (pool.next + 1) % len(available)
+		return available[pool.next]
+	}
+}
+
+// fail ejects the proxy after too many consecutive request
+// errors, since a single one can be a network hiccup
+func (pool *ProxyPool) fail(
+	ctx context.Context,
+	proxy *pooledProxy,
+	reason string,
+) {
+	pool.mu.Lock()
+	proxy.requestErrors++
+	if proxy.requestErrors < maxProxyRequestErrors {
+		pool.mu.Unlock()
+		return
+	}
+	pool.mu.Unlock()
+	pool.eject(ctx, proxy, reason)
+}
+
+// eject logs with the logger of ctx, so an ejection
+// caused by a request is logged with its attributes
+func (pool *ProxyPool) eject(
+	ctx context.Context,
+	proxy *pooledProxy,
+	reason string,
+) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	proxy.requestErrors = 0
+	cooldown := pool.cooldown << min(proxy.failures, maxProxyCooldownShift)
+	proxy.failures++
+	proxy.ejectedUntil = time.Now().Add(cooldown)
+	GetLogger(ctx).Warn(
+		"ejected proxy",
+		"proxy", proxy.url.Redacted(),
+		"pool", pool.name,
+		"cooldown", cooldown,
+		"reason", reason,
+	)
+}
+
+func (pool *ProxyPool) succeed(proxy *pooledProxy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	proxy.failures = 0
+	proxy.requestErrors = 0
+}
+
+func (pool *ProxyPool) runHealthChecks() {
+	ticker := time.NewTicker(pool.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pool.stop:
+			return
+		case <-ticker.C:
+			for _, proxy := range pool.proxies {
+				pool.checkProxy(proxy)
+			}
+		}
+	}
+}
+
+// checkProxy ejects unreachable proxies and
+// restores ejected proxies that work again
+func (pool *ProxyPool) checkProxy(proxy *pooledProxy) {
+	// the base transport could dial through the env
+	// socks5 proxy, so a clean one checks the proxy alone
+	transport := &http.Transport{
+		Proxy:               http.ProxyURL(proxy.url),
+		DialContext:         newDirectDialer().DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	}
+	defer transport.CloseIdleConnections()
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+	}
+	resp, err := client.Get(pool.healthURL)
+	if err != nil {
+		pool.eject(context.Background(), proxy, err.Error())
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		pool.eject(context.Background(), proxy, resp.Status)
+		return
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	proxy.failures = 0
+	proxy.requestErrors = 0
+	proxy.ejectedUntil = time.Time{}
+}
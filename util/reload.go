@@ -0,0 +1,100 @@
+package util
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"govd/config"
+)
+
+const defaultConfigWatchInterval = 10 // seconds
+
+// ReloadConfigs reloads the extractor configs, rebuilds
+// the clients of the extractors whose config changed and
+// refreshes changed cookie files. invalid configs are
+// rejected and the current ones are kept
+func ReloadConfigs() ([]string, error) {
+	changed, err := config.ReloadExtractorConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to reload extractor configs: %w", err)
+	}
+	RebuildHTTPClients(changed)
+	if len(changed) > 0 {
+		slog.Info("reloaded extractor configs", "extractors", strings.Join(changed, ", "))
+	}
+	ReloadCookieFiles()
+	return changed, nil
+}
+
+// StartConfigWatcher reloads the configs when the config
+// file changes or the process receives SIGHUP
+func StartConfigWatcher() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	var ticker <-chan time.Time
+	interval := getConfigWatchInterval()
+	if interval > 0 {
+		ticker = time.Tick(interval)
+	}
+
+	go func() {
+		lastModTime := getConfigModTime()
+		for {
+			select {
+			case <-signals:
+				slog.Info("received SIGHUP, reloading configs")
+			case <-ticker:
+				modTime := getConfigModTime()
+				if modTime.Equal(lastModTime) {
+					// cookie files are checked on each tick
+					ReloadCookieFiles()
+					continue
+				}
+			}
+			lastModTime = getConfigModTime()
+			_, err := ReloadConfigs()
+			if err != nil {
+				slog.Error("config reload failed", "error", err)
+			}
+		}
+	}()
+}
+
+func getConfigModTime() time.Time {
+	info, err := os.Stat(config.ConfigPath)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func getConfigWatchInterval() time.Duration {
+	value := os.Getenv("CONFIG_WATCH_INTERVAL")
+	if value == "" {
+		return defaultConfigWatchInterval * time.Second
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return defaultConfigWatchInterval * time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// IsBotAdmin reports whether the user is
+// listed in ADMINS env (comma separated ids)
+func IsBotAdmin(userID int64) bool {
+	for _, value := range strings.Split(os.Getenv("ADMINS"), ",") {
+		adminID, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err == nil && adminID == userID {
+			return true
+		}
+	}
+	return false
+}
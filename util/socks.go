@@ -0,0 +1,85 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/proxy"
+)
+
+func newDirectDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+}
+
+func isSOCKSProxy(proxyURL *url.URL) bool {
+	return proxyURL != nil &&
+		(proxyURL.Scheme == "socks5" || proxyURL.Scheme == "socks5h")
+}
+
+// configureSOCKSTransport routes the connections of the
+// transport through a socks5 proxy, authenticating with
+// the credentials of the url. hostnames are sent to the
+// proxy unresolved, so dns queries go through it too
+func configureSOCKSTransport(
+	transport *http.Transport,
+	proxyURL *url.URL,
+	noProxyList []string,
+) error {
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{
+			User:     proxyURL.User.Username(),
+			Password: password,
+		}
+	}
+	directDialer := newDirectDialer()
+	dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, directDialer)
+	if err != nil {
+		return fmt.Errorf("failed to create socks5 dialer: %w", err)
+	}
+	contextDialer, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		return errors.New("socks5 dialer doesn't support contexts")
+	}
+
+	transport.Proxy = nil
+	transport.DialContext = func(
+		ctx context.Context,
+		network string,
+		addr string,
+	) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err == nil && shouldBypassProxy(host, noProxyList) {
+			return directDialer.DialContext(ctx, network, addr)
+		}
+		return contextDialer.DialContext(ctx, network, addr)
+	}
+	return nil
+}
+
+// getEnvSOCKSProxy returns the proxy set in HTTPS_PROXY
+// or HTTP_PROXY env, if it's a socks5 proxy
+func getEnvSOCKSProxy() *url.URL {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		proxyURL, err := url.Parse(value)
+		if err != nil || !isSOCKSProxy(proxyURL) {
+			return nil
+		}
+		return proxyURL
+	}
+	return nil
+}